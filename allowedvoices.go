@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// allowedVoices returns the voices ALLOWED_VOICES (comma-separated) restricts
+// users to, or nil when every voice is available
+func allowedVoices() map[string]bool {
+	var allowed map[string]bool
+	for _, voice := range strings.Split(os.Getenv("ALLOWED_VOICES"), ",") {
+		if voice = strings.TrimSpace(voice); voice == "" {
+			continue
+		}
+		if allowed == nil {
+			allowed = map[string]bool{}
+		}
+		allowed[voice] = true
+	}
+	return allowed
+}
+
+// availableVoices returns the catalog voices of a model that users may pick
+func availableVoices(model string) []ttsVoice {
+	allowed := allowedVoices()
+	if allowed == nil {
+		return voiceCatalog[model]
+	}
+
+	voices := []ttsVoice{}
+	for _, voice := range voiceCatalog[model] {
+		if allowed[voice.ID] {
+			voices = append(voices, voice)
+		}
+	}
+	return voices
+}
+
+// checkVoiceAllowed returns an error when ALLOWED_VOICES is set and a request
+// for model would be spoken in a voice outside it, whether the voice was named
+// or is the one an omitted or unknown voice falls back to. A passthrough model
+// is sent upstream with the voice as given, so that voice is checked without
+// resolving it through the aliases; an omitted one is left to the backend and
+// can't be checked, so it is refused.
+func checkVoiceAllowed(model, voice string, passthrough bool) error {
+	allowed := allowedVoices()
+	if allowed == nil {
+		return nil
+	}
+
+	if !passthrough && (voice == "" || allowed[voice]) {
+		_, voice, _ = resolveTTSModel(model, voice)
+	}
+	if allowed[voice] {
+		return nil
+	}
+
+	names := make([]string, 0, len(allowed))
+	for name := range allowed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if voice == "" {
+		return fmt.Errorf("%s needs one of the allowed voices: %s", model, strings.Join(names, ", "))
+	}
+	return fmt.Errorf("voice %s is not allowed (allowed: %s)", voice, strings.Join(names, ", "))
+}
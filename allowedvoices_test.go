@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestCheckVoiceAllowed(t *testing.T) {
+	t.Setenv("ALLOWED_VOICES", "af_bella,en_US-amy-medium")
+
+	tests := []struct {
+		name        string
+		model       string
+		voice       string
+		passthrough bool
+		wantAllowed bool
+	}{
+		{"allowed alias voice", "tts-1", "af_bella", false, true},
+		{"other alias voice", "tts-1", "af_nova", false, false},
+		{"omitted voice falls back to an allowed one", "tts-1-piper", "", false, true},
+		{"passthrough with an allowed voice", "speaches-ai/Kokoro-82M-v1.0-ONNX", "af_bella", true, true},
+		{"passthrough with another voice", "speaches-ai/Kokoro-82M-v1.0-ONNX", "af_nova", true, false},
+		{"passthrough without a voice", "speaches-ai/Kokoro-82M-v1.0-ONNX", "", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkVoiceAllowed(tt.model, tt.voice, tt.passthrough)
+			if allowed := err == nil; allowed != tt.wantAllowed {
+				t.Errorf("checkVoiceAllowed(%q, %q, %v) = %v, want allowed %v", tt.model, tt.voice, tt.passthrough, err, tt.wantAllowed)
+			}
+		})
+	}
+}
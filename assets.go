@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+	"sync"
+)
+
+// assetsChecksum is the expected checksum of the embedded templates and
+// assets, set at build time with -ldflags "-X main.assetsChecksum=..."
+var assetsChecksum = ""
+
+// verifyAssets reports whether VERIFY_ASSETS=true, which refuses to start
+// when the embedded files don't match assetsChecksum
+func verifyAssets() bool {
+	return os.Getenv("VERIFY_ASSETS") == "true"
+}
+
+// checksumFS hashes every file of fsys into one SHA-256. It hashes the output
+// of sha256sum over the files sorted by path, so a build pipeline can compute
+// the same value with:
+//
+//	find assets templates -type f | LC_ALL=C sort | xargs sha256sum | sha256sum
+func checksumFS(fsys fs.FS) (string, int, error) {
+	var paths []string
+	err := fs.WalkDir(fsys, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			paths = append(paths, path)
+		}
+		return err
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	sort.Strings(paths)
+
+	combined := sha256.New()
+	for _, path := range paths {
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return "", 0, err
+		}
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(combined, "%s  %s\n", hex.EncodeToString(sum[:]), path)
+	}
+	return hex.EncodeToString(combined.Sum(nil)), len(paths), nil
+}
+
+// embeddedChecksum is the checksum of webAssets, computed once
+var embeddedChecksum = sync.OnceValues(func() (string, error) {
+	sum, files, err := checksumFS(webAssets)
+	if err == nil {
+		log.Printf("embedded assets: sha256 %s (%d files)", sum, files)
+	}
+	return sum, err
+})
+
+// checkEmbeddedAssets logs the checksum of the embedded files, so a running
+// build can be traced to its assets, and compares it with assetsChecksum when
+// one was baked in. A mismatch is only fatal with VERIFY_ASSETS=true.
+func checkEmbeddedAssets() error {
+	sum, err := embeddedChecksum()
+	if err != nil {
+		return fmt.Errorf("failed to read embedded assets: %w", err)
+	}
+
+	switch {
+	case assetsChecksum == "":
+		if verifyAssets() {
+			log.Printf("embedded assets: VERIFY_ASSETS=true but this build has no expected checksum, skipping the check")
+		}
+	case sum != assetsChecksum && verifyAssets():
+		return fmt.Errorf("embedded assets checksum %s does not match the expected %s; the build is corrupt or incomplete", sum, assetsChecksum)
+	case sum != assetsChecksum:
+		log.Printf("embedded assets: checksum %s does not match the expected %s", sum, assetsChecksum)
+	}
+	return nil
+}
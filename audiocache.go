@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultTTSCacheMaxEntries caps how many TTS results are cached
+	defaultTTSCacheMaxEntries = 100
+
+	// defaultTTSCacheTTL is how long a cached TTS result is served
+	defaultTTSCacheTTL = time.Hour
+)
+
+// ttsCacheMaxBytes returns the TTS result cache size limit, set with
+// TTS_CACHE_MAX_MB. The cache is off unless it is set.
+func ttsCacheMaxBytes() int {
+	if mb, err := strconv.Atoi(os.Getenv("TTS_CACHE_MAX_MB")); err == nil && mb > 0 {
+		return mb << 20
+	}
+	return 0
+}
+
+// ttsCacheMaxEntries returns the entry limit, overridable with TTS_CACHE_MAX_ENTRIES
+func ttsCacheMaxEntries() int {
+	if value, err := strconv.Atoi(os.Getenv("TTS_CACHE_MAX_ENTRIES")); err == nil && value > 0 {
+		return value
+	}
+	return defaultTTSCacheMaxEntries
+}
+
+// ttsCacheTTL returns how long a TTS result is cached, overridable with
+// TTS_CACHE_TTL (e.g. "10m", "0" disables the cache)
+func ttsCacheTTL() time.Duration {
+	value := os.Getenv("TTS_CACHE_TTL")
+	if value == "0" {
+		return 0
+	}
+	if ttl, err := time.ParseDuration(value); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultTTSCacheTTL
+}
+
+// ttsCacheEnabled reports whether /api/tts caches its results
+func ttsCacheEnabled() bool {
+	return ttsCacheMaxBytes() > 0 && ttsCacheTTL() > 0
+}
+
+// ttsCacheKey identifies a synthesis by the speech request sent upstream, which
+// holds the model, voice, speed, format, sample rate and text
+func ttsCacheKey(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// ttsResults holds the audio of recent /api/tts requests (TTS_CACHE_MAX_MB)
+var ttsResults = newAudioLRU(func() (time.Duration, int, int) {
+	return ttsCacheTTL(), ttsCacheMaxBytes(), ttsCacheMaxEntries()
+})
+
+func init() {
+	registerCache("tts", ttsResults.clear)
+}
+
+// cachedAudio is one cached piece of generated audio
+type cachedAudio struct {
+	key     string
+	audio   []byte
+	expires time.Time
+}
+
+// audioLRU caches generated audio, evicting the least recently used once the
+// entry or byte limit is reached
+type audioLRU struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used at the front
+	bytes   int
+
+	// limits returns the TTL, byte limit and entry limit; a zero TTL or byte
+	// limit disables the cache
+	limits func() (ttl time.Duration, maxBytes, maxEntries int)
+}
+
+// newAudioLRU returns an empty cache bounded by limits
+func newAudioLRU(limits func() (time.Duration, int, int)) *audioLRU {
+	return &audioLRU{entries: map[string]*list.Element{}, order: list.New(), limits: limits}
+}
+
+// get returns the cached audio for key if it has not expired
+func (p *audioLRU) get(key string) ([]byte, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	element, ok := p.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cachedAudio)
+	if time.Now().After(entry.expires) {
+		p.removeLocked(element)
+		return nil, false
+	}
+	p.order.MoveToFront(element)
+	return entry.audio, true
+}
+
+// put caches audio for key, evicting the least recently used entries to stay
+// within the limits. Audio larger than the whole cache is not kept.
+func (p *audioLRU) put(key string, audio []byte) {
+	ttl, maxBytes, maxEntries := p.limits()
+	if ttl == 0 || len(audio) > maxBytes {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if element, ok := p.entries[key]; ok {
+		p.removeLocked(element)
+	}
+	for p.order.Len() > 0 && (p.order.Len() >= maxEntries || p.bytes+len(audio) > maxBytes) {
+		p.removeLocked(p.order.Back())
+	}
+
+	p.entries[key] = p.order.PushFront(&cachedAudio{key: key, audio: audio, expires: time.Now().Add(ttl)})
+	p.bytes += len(audio)
+}
+
+// removeLocked drops one entry; p.mu must be held
+func (p *audioLRU) removeLocked(element *list.Element) {
+	entry := p.order.Remove(element).(*cachedAudio)
+	delete(p.entries, entry.key)
+	p.bytes -= len(entry.audio)
+}
+
+// clear drops every entry and returns how many there were
+func (p *audioLRU) clear() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	removed := p.order.Len()
+	p.entries, p.bytes = map[string]*list.Element{}, 0
+	p.order.Init()
+	return removed
+}
+
+// stats reports the current size of the cache and its limits
+func (p *audioLRU) stats() gin.H {
+	ttl, maxBytes, maxEntries := p.limits()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return gin.H{
+		"entries":     p.order.Len(),
+		"bytes":       p.bytes,
+		"max_entries": maxEntries,
+		"max_bytes":   maxBytes,
+		"ttl_s":       ttl.Seconds(),
+	}
+}
+
+// cappedBuffer collects a copy of streamed audio for the cache, giving up once
+// it grows past limit so a long stream isn't held in memory for nothing
+type cappedBuffer struct {
+	bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+// Write keeps p while the buffer is within its limit; it never fails, so it
+// can't disturb the stream it copies
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.overflow || b.Len()+len(p) > b.limit {
+		b.overflow = true
+		b.Reset()
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
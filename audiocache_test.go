@@ -0,0 +1,138 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// testLimits returns fixed cache limits for newAudioLRU
+func testLimits(ttl time.Duration, maxBytes, maxEntries int) func() (time.Duration, int, int) {
+	return func() (time.Duration, int, int) { return ttl, maxBytes, maxEntries }
+}
+
+func TestAudioLRU(t *testing.T) {
+	type step struct {
+		op   string // "put" or "get"
+		key  string
+		size int  // bytes to put
+		hit  bool // expected result of get
+	}
+
+	tests := []struct {
+		name       string
+		maxBytes   int
+		maxEntries int
+		steps      []step
+		wantBytes  int
+	}{
+		{
+			name: "miss then hit", maxBytes: 100, maxEntries: 10,
+			steps: []step{
+				{op: "get", key: "a"},
+				{op: "put", key: "a", size: 10},
+				{op: "get", key: "a", hit: true},
+			},
+			wantBytes: 10,
+		},
+		{
+			name: "entry limit evicts least recently used", maxBytes: 100, maxEntries: 2,
+			steps: []step{
+				{op: "put", key: "a", size: 1},
+				{op: "put", key: "b", size: 1},
+				{op: "get", key: "a", hit: true},
+				{op: "put", key: "c", size: 1},
+				{op: "get", key: "b"},
+				{op: "get", key: "a", hit: true},
+				{op: "get", key: "c", hit: true},
+			},
+			wantBytes: 2,
+		},
+		{
+			name: "byte limit evicts until the entry fits", maxBytes: 10, maxEntries: 10,
+			steps: []step{
+				{op: "put", key: "a", size: 4},
+				{op: "put", key: "b", size: 4},
+				{op: "put", key: "c", size: 4},
+				{op: "get", key: "a"},
+				{op: "get", key: "b", hit: true},
+				{op: "get", key: "c", hit: true},
+			},
+			wantBytes: 8,
+		},
+		{
+			name: "entry larger than the cache is not kept", maxBytes: 10, maxEntries: 10,
+			steps: []step{
+				{op: "put", key: "a", size: 4},
+				{op: "put", key: "big", size: 11},
+				{op: "get", key: "big"},
+				{op: "get", key: "a", hit: true},
+			},
+			wantBytes: 4,
+		},
+		{
+			name: "replacing a key frees its old size", maxBytes: 10, maxEntries: 10,
+			steps: []step{
+				{op: "put", key: "a", size: 8},
+				{op: "put", key: "a", size: 3},
+				{op: "get", key: "a", hit: true},
+			},
+			wantBytes: 3,
+		},
+		{
+			name: "zero byte limit disables the cache", maxBytes: 0, maxEntries: 10,
+			steps: []step{
+				{op: "put", key: "a", size: 1},
+				{op: "get", key: "a"},
+			},
+			wantBytes: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newAudioLRU(testLimits(time.Hour, tt.maxBytes, tt.maxEntries))
+			for i, s := range tt.steps {
+				switch s.op {
+				case "put":
+					cache.put(s.key, make([]byte, s.size))
+				case "get":
+					if _, hit := cache.get(s.key); hit != s.hit {
+						t.Fatalf("step %d: get(%q) hit = %v, want %v", i, s.key, hit, s.hit)
+					}
+				}
+			}
+			if cache.bytes != tt.wantBytes {
+				t.Errorf("bytes = %d, want %d", cache.bytes, tt.wantBytes)
+			}
+		})
+	}
+}
+
+func TestAudioLRUExpires(t *testing.T) {
+	cache := newAudioLRU(testLimits(time.Hour, 100, 10))
+	cache.put("a", []byte("audio"))
+
+	cache.entries["a"].Value.(*cachedAudio).expires = time.Now().Add(-time.Second)
+	if _, hit := cache.get("a"); hit {
+		t.Fatal("get returned an expired entry")
+	}
+	if cache.order.Len() != 0 || cache.bytes != 0 {
+		t.Errorf("expired entry was not removed: %d entries, %d bytes", cache.order.Len(), cache.bytes)
+	}
+}
+
+func TestAudioLRUClear(t *testing.T) {
+	cache := newAudioLRU(testLimits(time.Hour, 100, 10))
+	cache.put("a", []byte("one"))
+	cache.put("b", []byte("two"))
+
+	if removed := cache.clear(); removed != 2 {
+		t.Errorf("clear() = %d, want 2", removed)
+	}
+	if _, hit := cache.get("a"); hit {
+		t.Error("get hit after clear")
+	}
+	if cache.bytes != 0 {
+		t.Errorf("bytes = %d after clear, want 0", cache.bytes)
+	}
+}
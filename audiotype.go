@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// defaultSTTAllowedMIMETypes are the upload types /api/stt accepts when STT_ALLOWED_MIME_TYPES is unset
+var defaultSTTAllowedMIMETypes = []string{
+	"audio/wav",
+	"audio/mpeg",
+	"audio/flac",
+	"audio/ogg",
+	"audio/webm",
+	"audio/mp4",
+	"audio/aac",
+}
+
+// sttAudioExtensions are the file extensions of the audio formats speaches.ai accepts
+var sttAudioExtensions = []string{".wav", ".mp3", ".m4a", ".mp4", ".aac", ".flac", ".ogg", ".opus", ".webm"}
+
+// mimeTypeAliases maps alternative names of audio types to the names sniffAudioType returns
+var mimeTypeAliases = map[string]string{
+	"audio/x-wav":     "audio/wav",
+	"audio/wave":      "audio/wav",
+	"audio/vnd.wave":  "audio/wav",
+	"audio/mp3":       "audio/mpeg",
+	"audio/x-flac":    "audio/flac",
+	"audio/x-m4a":     "audio/mp4",
+	"audio/m4a":       "audio/mp4",
+	"video/webm":      "audio/webm",
+	"video/mp4":       "audio/mp4",
+	"application/ogg": "audio/ogg",
+	"audio/x-aac":     "audio/aac",
+}
+
+// normalizeMIMEType lowercases a MIME type, drops parameters and resolves aliases
+func normalizeMIMEType(mimeType string) string {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	if canonical, ok := mimeTypeAliases[mimeType]; ok {
+		return canonical
+	}
+	return mimeType
+}
+
+// sttAllowedMIMETypes returns the accepted upload types, overridable with
+// STT_ALLOWED_MIME_TYPES (comma-separated). nil means any type, set with "*".
+func sttAllowedMIMETypes() map[string]bool {
+	types := defaultSTTAllowedMIMETypes
+	if value := os.Getenv("STT_ALLOWED_MIME_TYPES"); strings.TrimSpace(value) != "" {
+		types = strings.Split(value, ",")
+	}
+
+	allowed := map[string]bool{}
+	for _, mimeType := range types {
+		mimeType = normalizeMIMEType(mimeType)
+		if mimeType == "*" || mimeType == "*/*" {
+			return nil
+		}
+		if mimeType != "" {
+			allowed[mimeType] = true
+		}
+	}
+	return allowed
+}
+
+// sortedMIMETypes lists an allowlist for error messages
+func sortedMIMETypes(allowed map[string]bool) string {
+	types := make([]string, 0, len(allowed))
+	for mimeType := range allowed {
+		types = append(types, mimeType)
+	}
+	sort.Strings(types)
+	return strings.Join(types, ", ")
+}
+
+// sniffAudioType identifies an audio file from its leading bytes, ignoring its
+// name. It returns "" when the format is not recognized.
+func sniffAudioType(data []byte) string {
+	switch {
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE":
+		return "audio/wav"
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		return "audio/flac"
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return "audio/ogg"
+	case bytes.HasPrefix(data, []byte{0x1A, 0x45, 0xDF, 0xA3}): // EBML header of WebM/Matroska
+		return "audio/webm"
+	case len(data) >= 12 && string(data[4:8]) == "ftyp": // ISO base media (m4a, mp4)
+		return "audio/mp4"
+	case bytes.HasPrefix(data, []byte("ID3")):
+		return "audio/mpeg"
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xF6 == 0xF0: // ADTS frame sync with layer 0
+		return "audio/aac"
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0 && data[1]&0x06 != 0: // MPEG audio frame sync
+		return "audio/mpeg"
+	}
+	return ""
+}
+
+// checkIsAudio rejects uploads that are clearly not audio, such as a text file
+// or an image picked by mistake. The content is sniffed with
+// http.DetectContentType and sniffAudioType; content neither recognizes is
+// left to the allowlist, which refuses it unless STT_ALLOWED_MIME_TYPES is "*".
+func checkIsAudio(data []byte) error {
+	if sniffAudioType(data) != "" {
+		return nil
+	}
+
+	detected, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	switch {
+	case strings.HasPrefix(detected, "audio/"), strings.HasPrefix(detected, "video/"), detected == "application/ogg":
+		return nil
+	case detected != "application/octet-stream":
+		return fmt.Errorf("the uploaded file is %s, not audio (supported formats: %s)", detected, strings.Join(sttAudioExtensions, ", "))
+	}
+	return nil
+}
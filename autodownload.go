@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strconv"
+)
+
+// autoDownloadDefault reports whether a missing model is downloaded and the
+// request retried. AUTO_DOWNLOAD=false turns this off for requests that don't
+// ask for it themselves.
+func autoDownloadDefault() bool {
+	return os.Getenv("AUTO_DOWNLOAD") != "false"
+}
+
+// autoDownloadKey is the context key of a request's autodownload choice
+type autoDownloadKey struct{}
+
+// withAutoDownload returns ctx carrying a request's autodownload choice; nil
+// leaves the AUTO_DOWNLOAD default in place
+func withAutoDownload(ctx context.Context, enabled *bool) context.Context {
+	if enabled == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, autoDownloadKey{}, *enabled)
+}
+
+// autoDownloadAllowed reports whether the request ctx belongs to may download
+// a missing model
+func autoDownloadAllowed(ctx context.Context) bool {
+	if enabled, ok := ctx.Value(autoDownloadKey{}).(bool); ok {
+		return enabled
+	}
+	return autoDownloadDefault()
+}
+
+// parseAutoDownload reads the optional autodownload form field of an upload;
+// nil means it was not sent
+func parseAutoDownload(value string) (*bool, error) {
+	if value == "" {
+		return nil, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, err
+	}
+	return &enabled, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// benchmarkPhrase is the short text every benchmarked voice synthesizes
+	benchmarkPhrase = "Testing one, two, three."
+
+	// benchmarkConcurrency bounds how many voices are synthesized at once
+	benchmarkConcurrency = 2
+
+	// maxBenchmarkVoices bounds how many voices one benchmark may time
+	maxBenchmarkVoices = 10
+)
+
+// benchmarkModel returns the TTS model a voice belongs to, or "" for an unknown voice
+func benchmarkModel(voice string) string {
+	for _, m := range ttsModels {
+		if ttsVoices[m.ID][voice] {
+			return m.ID
+		}
+	}
+	return ""
+}
+
+// handleVoicesBenchmark synthesizes a short fixed phrase with each requested
+// voice and reports the round-trip latency, for spotting slow voices and
+// sizing the backend. Results are never cached so they reflect current load.
+func handleVoicesBenchmark(c *gin.Context) {
+	// Time each model's default voice when none are given
+	voices := []string{}
+	for _, voice := range strings.Split(c.Query("voices"), ",") {
+		if voice = strings.TrimSpace(voice); voice != "" {
+			voices = append(voices, voice)
+		}
+	}
+	if len(voices) == 0 {
+		for _, m := range ttsModels {
+			voices = append(voices, defaultVoice(m.ID))
+		}
+	}
+	if len(voices) > maxBenchmarkVoices {
+		jsonError(c, http.StatusBadRequest, "too many voices (at most 10 per benchmark)")
+		return
+	}
+
+	models := make([]string, len(voices))
+	for i, voice := range voices {
+		models[i] = benchmarkModel(voice)
+		if models[i] == "" {
+			jsonError(c, http.StatusBadRequest, "unknown voice: "+voice)
+			return
+		}
+		if err := checkVoiceAllowed(models[i], voice, false); err != nil {
+			jsonErrorCode(c, http.StatusForbidden, errCodeVoiceNotAllowed, err.Error())
+			return
+		}
+	}
+
+	baseURL := speachesBaseURL()
+
+	ctx, cancel := upstreamContext(c)
+	defer cancel()
+
+	// Time each voice with bounded concurrency, keeping the request order
+	results := make([]gin.H, len(voices))
+	sem := make(chan struct{}, benchmarkConcurrency)
+	var wg sync.WaitGroup
+	for i := range voices {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = benchmarkVoice(ctx, baseURL, models[i], voices[i])
+		}(i)
+	}
+	wg.Wait()
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"phrase":  benchmarkPhrase,
+		"results": results,
+	})
+}
+
+// benchmarkVoice synthesizes the benchmark phrase with one voice and returns its result entry
+func benchmarkVoice(ctx context.Context, speachesBaseURL, model, voice string) gin.H {
+	opts := ttsRequest{Model: model, Voice: voice}.options()
+
+	// Never cached, so the timing reflects the current load
+	start := time.Now()
+	entry, audio, firstByte := synthesizeEntry(ctx, speachesBaseURL, opts, benchmarkPhrase, nil)
+	if audio == nil {
+		return entry
+	}
+
+	entry["latency_ms"] = time.Since(start).Milliseconds()
+	entry["first_byte_ms"] = firstByte.Milliseconds()
+	return entry
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cacheClearer empties one in-memory cache and returns how many entries it removed
+type cacheClearer func() int
+
+var (
+	cachesMu sync.Mutex
+	caches   = map[string]cacheClearer{}
+)
+
+// registerCache makes an in-memory cache clearable through the admin endpoint
+func registerCache(name string, clear cacheClearer) {
+	cachesMu.Lock()
+	defer cachesMu.Unlock()
+	caches[name] = clear
+}
+
+// requireAdmin only lets requests through that carry "Authorization: Bearer $ADMIN_TOKEN".
+// Admin endpoints are disabled entirely when ADMIN_TOKEN is unset.
+func requireAdmin(c *gin.Context) {
+	token := os.Getenv("ADMIN_TOKEN")
+	if token == "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, errorResponse{Error: "admin endpoints are disabled; set ADMIN_TOKEN to enable them", Code: errCodeForbidden})
+		return
+	}
+
+	provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse{Error: "invalid admin token", Code: errCodeUnauthorized})
+		return
+	}
+
+	c.Next()
+}
+
+// handleClearCaches flushes the in-memory caches, or only the one named by ?type=
+func handleClearCaches(c *gin.Context) {
+	cachesMu.Lock()
+	defer cachesMu.Unlock()
+
+	cacheType := c.Query("type")
+	if cacheType != "" {
+		if _, ok := caches[cacheType]; !ok {
+			names := make([]string, 0, len(caches))
+			for name := range caches {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":     "unknown cache type: " + cacheType,
+				"code":      errCodeInvalidInput,
+				"available": names,
+			})
+			return
+		}
+	}
+
+	cleared := gin.H{}
+	for name, clear := range caches {
+		if cacheType == "" || name == cacheType {
+			cleared[name] = clear()
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cleared": cleared})
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultTTSChunkMaxChars is the longest chunk sent in a single synthesis request
+const defaultTTSChunkMaxChars = 1000
+
+// textChunk is one piece of a long text, with rune offsets into the original input
+type textChunk struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// ttsChunkMaxChars returns the chunk size limit, overridable with TTS_CHUNK_MAX_CHARS
+func ttsChunkMaxChars() int {
+	if value, err := strconv.Atoi(os.Getenv("TTS_CHUNK_MAX_CHARS")); err == nil && value > 0 {
+		return value
+	}
+	return defaultTTSChunkMaxChars
+}
+
+// splitTextIntoChunks splits text at sentence boundaries into chunks of at most
+// maxChars characters. Sentences longer than maxChars are cut at the last
+// whitespace before the limit, or at the limit itself if there is none.
+func splitTextIntoChunks(text string, maxChars int) []textChunk {
+	runes := []rune(text)
+	chunks := []textChunk{}
+
+	emit := func(start, end int) {
+		start, end = trimSpan(runes, start, end)
+		if start < end {
+			chunks = append(chunks, textChunk{Start: start, End: end, Text: string(runes[start:end])})
+		}
+	}
+
+	curStart, curEnd := -1, -1
+	for _, sentence := range splitSentences(runes) {
+		start, end := sentence[0], sentence[1]
+
+		// Break up sentences that cannot fit in a chunk on their own
+		for end-start > maxChars {
+			if curStart >= 0 {
+				emit(curStart, curEnd)
+				curStart = -1
+			}
+			cut := start + maxChars
+			for i := cut; i > start; i-- {
+				if unicode.IsSpace(runes[i]) {
+					cut = i
+					break
+				}
+			}
+			emit(start, cut)
+			start, _ = trimSpan(runes, cut, end)
+		}
+		if start >= end {
+			continue
+		}
+
+		if curStart >= 0 && end-curStart > maxChars {
+			emit(curStart, curEnd)
+			curStart = -1
+		}
+		if curStart < 0 {
+			curStart = start
+		}
+		curEnd = end
+	}
+	if curStart >= 0 {
+		emit(curStart, curEnd)
+	}
+
+	return chunks
+}
+
+// splitSentenceChunks splits text into one chunk per sentence, so chunk
+// boundaries are sentence boundaries. Sentences longer than maxChars are cut
+// as in splitTextIntoChunks.
+func splitSentenceChunks(text string, maxChars int) []textChunk {
+	runes := []rune(text)
+	chunks := []textChunk{}
+	for _, sentence := range splitSentences(runes) {
+		for _, chunk := range splitTextIntoChunks(string(runes[sentence[0]:sentence[1]]), maxChars) {
+			chunk.Start += sentence[0]
+			chunk.End += sentence[0]
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks
+}
+
+// splitSentences returns trimmed [start, end) rune spans for each sentence or line
+func splitSentences(runes []rune) [][2]int {
+	var spans [][2]int
+	add := func(start, end int) {
+		start, end = trimSpan(runes, start, end)
+		if start < end {
+			spans = append(spans, [2]int{start, end})
+		}
+	}
+
+	start := 0
+	for i, r := range runes {
+		endsSentence := r == '.' || r == '!' || r == '?' || r == '…'
+		if r == '\n' || (endsSentence && (i+1 == len(runes) || unicode.IsSpace(runes[i+1]))) {
+			add(start, i+1)
+			start = i + 1
+		}
+	}
+	add(start, len(runes))
+
+	return spans
+}
+
+// trimSpan narrows a rune span so it neither starts nor ends with whitespace
+func trimSpan(runes []rune, start, end int) (int, int) {
+	for start < end && unicode.IsSpace(runes[start]) {
+		start++
+	}
+	for end > start && unicode.IsSpace(runes[end-1]) {
+		end--
+	}
+	return start, end
+}
+
+// handleTTSChunks previews how long text will be split for synthesis without synthesizing it
+func handleTTSChunks(c *gin.Context) {
+	var req struct {
+		Text string `json:"text" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		jsonError(c, http.StatusBadRequest, "text field is required")
+		return
+	}
+
+	// Offsets refer to the normalized text, which is what /api/tts/long splits
+	req.Text = normalizeTTSText(req.Text)
+
+	maxChars := ttsChunkMaxChars()
+	chunks := splitTextIntoChunks(req.Text, maxChars)
+
+	response := make([]gin.H, len(chunks))
+	for i, chunk := range chunks {
+		response[i] = gin.H{
+			"index": i,
+			"start": chunk.Start,
+			"end":   chunk.End,
+			"chars": chunk.End - chunk.Start,
+			"text":  chunk.Text,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total_chars": len([]rune(req.Text)),
+		"max_chars":   maxChars,
+		"count":       len(chunks),
+		"chunks":      response,
+	})
+}
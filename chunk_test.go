@@ -0,0 +1,84 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitTextIntoChunks(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		maxChars int
+		want     []textChunk
+	}{
+		{
+			name:     "fits in one chunk",
+			text:     "One. Two.",
+			maxChars: 100,
+			want:     []textChunk{{0, 9, "One. Two."}},
+		},
+		{
+			name:     "sentences split at the limit",
+			text:     "One. Two. Three.",
+			maxChars: 10,
+			want:     []textChunk{{0, 9, "One. Two."}, {10, 16, "Three."}},
+		},
+		{
+			name:     "newlines end sentences",
+			text:     "One\nTwo",
+			maxChars: 4,
+			want:     []textChunk{{0, 3, "One"}, {4, 7, "Two"}},
+		},
+		{
+			name:     "long sentence cut at whitespace",
+			text:     "aaaa bbbb cccc",
+			maxChars: 6,
+			want:     []textChunk{{0, 4, "aaaa"}, {5, 9, "bbbb"}, {10, 14, "cccc"}},
+		},
+		{
+			name:     "long word cut at the limit",
+			text:     "abcdefgh",
+			maxChars: 3,
+			want:     []textChunk{{0, 3, "abc"}, {3, 6, "def"}, {6, 8, "gh"}},
+		},
+		{
+			name:     "offsets count runes",
+			text:     "Ça va. Très bien.",
+			maxChars: 10,
+			want:     []textChunk{{0, 6, "Ça va."}, {7, 17, "Très bien."}},
+		},
+		{
+			name:     "whitespace only",
+			text:     " \n\t ",
+			maxChars: 10,
+			want:     []textChunk{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitTextIntoChunks(tt.text, tt.maxChars)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("splitTextIntoChunks(%q, %d) = %v, want %v", tt.text, tt.maxChars, got, tt.want)
+			}
+			runes := []rune(tt.text)
+			for _, chunk := range got {
+				if string(runes[chunk.Start:chunk.End]) != chunk.Text {
+					t.Errorf("chunk %v does not match its offsets", chunk)
+				}
+			}
+		})
+	}
+}
+
+func TestChunkOffsetsAfterNormalizing(t *testing.T) {
+	// /api/tts/chunks and /api/tts/long must split the same text, so offsets
+	// refer to the input after its BOM, CRLFs and outer whitespace are gone
+	text := normalizeTTSText("\ufeff  First line.\r\nSecond line.\r\n")
+	got := splitTextIntoChunks(text, 12)
+	want := []textChunk{{0, 11, "First line."}, {12, 24, "Second line."}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitTextIntoChunks(%q) = %v, want %v", text, got, want)
+	}
+}
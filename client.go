@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultSpeachesTimeout caps a call to speaches.ai that has no deadline of
+// its own, including reading the response
+const defaultSpeachesTimeout = 600 * time.Second
+
+// speachesClient is the shared HTTP client for every call to speaches.ai
+var speachesClient = newSpeachesClient()
+
+// speachesTimeout returns the default per-call limit, overridable with SPEACHES_TIMEOUT in seconds
+func speachesTimeout() time.Duration {
+	if seconds, err := strconv.Atoi(os.Getenv("SPEACHES_TIMEOUT")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultSpeachesTimeout
+}
+
+// authTransport adds the speaches.ai API key to every outbound request
+type authTransport struct {
+	apiKey string
+	base   http.RoundTripper
+}
+
+// RoundTrip sends req with an Authorization header, on a copy since a
+// RoundTripper must not modify the caller's request
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	return t.base.RoundTrip(req)
+}
+
+// deadlineTransport gives a call without a deadline of its own SPEACHES_TIMEOUT,
+// so a hung backend can't pile up requests. Calls that set their own deadline,
+// such as TTS and STT (X-Timeout-Ms) or model installs, keep it; a client-wide
+// timeout would cut those off.
+type deadlineTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip sends req, bounded by SPEACHES_TIMEOUT unless its context already
+// has a deadline. The timeout covers reading the response body too.
+func (t *deadlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, ok := req.Context().Deadline(); ok {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), speachesTimeout())
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody releases a deadlineTransport timeout when the response body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and releases its timeout
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// newSpeachesClient builds the client with a connection pool sized for one
+// busy upstream. Calls without a deadline are limited to SPEACHES_TIMEOUT.
+// Each call is noted in its request's trace, and when SPEACHES_API_KEY is set
+// it is authenticated with it. TTS and STT calls are limited to
+// SPEACHES_MAX_CONCURRENCY at a time.
+func newSpeachesClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 20
+	transport.IdleConnTimeout = 90 * time.Second
+
+	var roundTripper http.RoundTripper = &traceTransport{base: transport}
+	if apiKey := os.Getenv("SPEACHES_API_KEY"); apiKey != "" {
+		roundTripper = &authTransport{apiKey: apiKey, base: roundTripper}
+	}
+	if limit := maxConcurrency(); limit > 0 {
+		roundTripper = &limitTransport{slots: make(chan struct{}, limit), base: roundTripper}
+	}
+
+	return &http.Client{
+		Transport: &deadlineTransport{base: roundTripper},
+	}
+}
+
+// speachesGet sends a GET to speaches.ai as part of the request ctx belongs
+// to, so the call is logged under its request id and canceled with it. GETs
+// are idempotent, so transient failures are retried.
+func speachesGet(ctx context.Context, url string) (*http.Response, error) {
+	return retryTransient(ctx, func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		return speachesClient.Do(req)
+	})
+}
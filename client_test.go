@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeadlineTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(1500 * time.Millisecond):
+			io.WriteString(w, "done")
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	t.Setenv("SPEACHES_TIMEOUT", "1")
+
+	client := &http.Client{Transport: &deadlineTransport{base: http.DefaultTransport}}
+	get := func(ctx context.Context) error {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, err = io.ReadAll(resp.Body)
+		return err
+	}
+
+	t.Run("no deadline gets SPEACHES_TIMEOUT", func(t *testing.T) {
+		if err := get(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+
+	t.Run("own deadline is kept", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := get(ctx); err != nil {
+			t.Errorf("call with a longer deadline failed: %v", err)
+		}
+	})
+}
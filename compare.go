@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// compareConcurrency bounds how many syntheses a model comparison runs at once
+const compareConcurrency = 2
+
+// handleTTSModelsCompare synthesizes the same text with several model families
+// and returns a manifest of inline audio clips for A/B listening
+func handleTTSModelsCompare(c *gin.Context) {
+	var req struct {
+		Text   string            `json:"text" binding:"required"`
+		Voices map[string]string `json:"voices"` // model -> voice, e.g. {"tts-1": "af_nova"}
+		Format string            `json:"format"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		jsonError(c, http.StatusBadRequest, "text field is required")
+		return
+	}
+
+	// Whitespace-only text would only synthesize silence
+	req.Text = normalizeTTSText(req.Text)
+	if req.Text == "" {
+		jsonErrorCode(c, http.StatusBadRequest, errCodeEmptyInput, "text cannot be empty")
+		return
+	}
+
+	if err := validateTTSFormat(req.Format); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Compare every model family with its default voice when none are given
+	voices := req.Voices
+	if len(voices) == 0 {
+		voices = map[string]string{}
+		for _, m := range ttsModels {
+			voices[m.ID] = ""
+		}
+	}
+
+	models := make([]string, 0, len(voices))
+	for model := range voices {
+		// Only the offered families can be compared; any other model would fall back to the default
+		if err := checkTTSModel(model); err != nil {
+			jsonErrorCode(c, http.StatusBadRequest, errCodeUnknownModel, err.Error())
+			return
+		}
+		if err := validateTTSVoice(model, voices[model]); err != nil {
+			jsonError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := checkVoiceAllowed(model, voices[model], false); err != nil {
+			jsonErrorCode(c, http.StatusForbidden, errCodeVoiceNotAllowed, err.Error())
+			return
+		}
+		models = append(models, model)
+	}
+	sort.Strings(models)
+
+	format := ttsRequest{Format: req.Format}.options().Format
+
+	baseURL := speachesBaseURL()
+
+	ctx, cancel := upstreamContext(c)
+	defer cancel()
+
+	// Synthesize each model with bounded concurrency, keeping the manifest order stable
+	results := make([]gin.H, len(models))
+	sem := make(chan struct{}, compareConcurrency)
+	var wg sync.WaitGroup
+	for i, model := range models {
+		wg.Add(1)
+		go func(i int, model string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = compareModel(ctx, baseURL, model, voices[model], req.Text, format)
+		}(i, model)
+	}
+	wg.Wait()
+
+	c.JSON(http.StatusOK, gin.H{
+		"text":    req.Text,
+		"format":  format,
+		"results": results,
+	})
+}
+
+// compareModel synthesizes text with one model and returns its manifest entry
+func compareModel(ctx context.Context, speachesBaseURL, model, voice, text, format string) gin.H {
+	opts := ttsRequest{Model: model, Voice: voice, Format: format}.options()
+
+	// Repeated comparisons are served from the preview cache
+	start := time.Now()
+	entry, audio, _ := synthesizeEntry(ctx, speachesBaseURL, opts, text, voicePreviews)
+	if audio == nil {
+		return entry
+	}
+
+	contentType := ttsFormats[format]
+	entry["content_type"] = contentType
+	entry["latency_ms"] = time.Since(start).Milliseconds()
+	entry["audio"] = "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(audio)
+	return entry
+}
+
+// synthesizeEntry synthesizes text with opts for the manifests of the compare
+// and benchmark endpoints. The entry names the model and voice, and the bytes
+// and any downloaded model on success; on failure it carries the error, with
+// the upstream error body, and audio is nil. A non-nil cache is checked first
+// and filled on success, keyed like the TTS cache, and the entry reports
+// whether it was a hit. firstByte is the time to the response headers.
+func synthesizeEntry(ctx context.Context, speachesBaseURL string, opts ttsOptions, text string, cache *audioLRU) (entry gin.H, audio []byte, firstByte time.Duration) {
+	entry = gin.H{
+		"model":        opts.Model,
+		"voice":        opts.Voice,
+		"actual_model": opts.ActualModel,
+	}
+
+	jsonPayload, err := opts.payload(text)
+	if err != nil {
+		entry["error"] = "failed to marshal request"
+		return entry, nil, 0
+	}
+
+	cacheKey := ttsCacheKey(jsonPayload)
+	if cache != nil {
+		if audio, ok := cache.get(cacheKey); ok {
+			entry["bytes"] = len(audio)
+			entry["cached"] = true
+			return entry, audio, 0
+		}
+		entry["cached"] = false
+	}
+
+	start := time.Now()
+	resp, downloaded, err := postSpeech(ctx, speachesBaseURL, opts.Model, opts.Voice, jsonPayload)
+	if err != nil {
+		_, failure := upstreamCallError(err)
+		entry["error"] = failure.Error
+		return entry, nil, 0
+	}
+	defer resp.Body.Close()
+	firstByte = time.Since(start)
+
+	audio, err = io.ReadAll(resp.Body)
+	if err != nil {
+		entry["error"] = "failed to read server response"
+		return entry, nil, 0
+	}
+	if isSpeechError(resp) {
+		failure := upstreamError(audio)
+		entry["error"] = failure.Error
+		if failure.Upstream != nil {
+			entry["upstream"] = failure.Upstream
+		}
+		return entry, nil, 0
+	}
+
+	if cache != nil {
+		cache.put(cacheKey, audio)
+	}
+	// A download explains a slow result, and makes a benchmark timing meaningless
+	if downloaded != "" {
+		entry["downloaded_model"] = downloaded
+	}
+	entry["bytes"] = len(audio)
+	return entry, audio, firstByte
+}
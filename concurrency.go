@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultMaxConcurrency bounds the TTS and STT calls in flight to speaches.ai
+	defaultMaxConcurrency = 4
+
+	// defaultQueueTimeout is how long a call waits for a free slot before giving up
+	defaultQueueTimeout = 30 * time.Second
+)
+
+// errUpstreamBusy is returned when a TTS or STT call waited too long for a free slot
+var errUpstreamBusy = errors.New("speaches.ai is busy, try again shortly")
+
+// maxConcurrency returns how many TTS and STT calls may be in flight at once,
+// overridable with SPEACHES_MAX_CONCURRENCY ("0" removes the limit)
+func maxConcurrency() int {
+	if value, err := strconv.Atoi(os.Getenv("SPEACHES_MAX_CONCURRENCY")); err == nil && value >= 0 {
+		return value
+	}
+	return defaultMaxConcurrency
+}
+
+// queueTimeout returns how long a call waits for a slot, overridable with
+// SPEACHES_QUEUE_TIMEOUT (e.g. "10s", "0" fails at once when all slots are taken)
+func queueTimeout() time.Duration {
+	value := os.Getenv("SPEACHES_QUEUE_TIMEOUT")
+	if value == "0" {
+		return 0
+	}
+	if timeout, err := time.ParseDuration(value); err == nil && timeout > 0 {
+		return timeout
+	}
+	return defaultQueueTimeout
+}
+
+// limitTransport lets at most cap(slots) speech and transcription calls reach
+// speaches.ai at once, queueing the rest. A slot is held until the response
+// body is closed, so streamed audio counts until it has been sent.
+type limitTransport struct {
+	slots chan struct{}
+	base  http.RoundTripper
+}
+
+// RoundTrip waits for a slot for /v1/audio/ calls; model listings, installs
+// and health checks are cheap and pass straight through
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.Contains(req.URL.Path, "/v1/audio/") {
+		return t.base.RoundTrip(req)
+	}
+
+	timer := time.NewTimer(queueTimeout())
+	defer timer.Stop()
+	select {
+	case t.slots <- struct{}{}:
+	case <-req.Context().Done():
+		closeRequestBody(req)
+		return nil, req.Context().Err()
+	case <-timer.C:
+		closeRequestBody(req)
+		return nil, errUpstreamBusy
+	}
+
+	var once sync.Once
+	release := func() { once.Do(func() { <-t.slots }) }
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// closeRequestBody closes the body of a request that is never sent, as a
+// RoundTripper must. For a streamed STT upload this ends the goroutine writing
+// the multipart form and closes the uploaded file.
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// releaseBody frees a limitTransport slot when the response body is closed
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+// Close closes the body and frees its slot
+func (b *releaseBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
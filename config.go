@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultSpeachesURL is the speaches.ai address used when SPEACHES_URL is unset
+const defaultSpeachesURL = "http://localhost:8000"
+
+// speachesBaseURL returns the speaches.ai server address from SPEACHES_URL,
+// without a trailing slash so paths can be appended directly. It is read once.
+var speachesBaseURL = sync.OnceValue(func() string {
+	raw := strings.TrimRight(strings.TrimSpace(os.Getenv("SPEACHES_URL")), "/")
+	if raw == "" {
+		return defaultSpeachesURL
+	}
+	return raw
+})
+
+// checkSpeachesURL fails when SPEACHES_URL is not an http(s) URL with a host,
+// so a typo is caught at startup rather than on the first request
+func checkSpeachesURL() error {
+	u, err := url.Parse(speachesBaseURL())
+	if err != nil {
+		return fmt.Errorf("SPEACHES_URL is not a valid URL: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("SPEACHES_URL %q must be an http:// or https:// URL with a host", speachesBaseURL())
+	}
+	return nil
+}
+
+// checkSpeachesURLRequired fails when REQUIRE_SPEACHES_URL=true and SPEACHES_URL
+// is unset, so production deployments don't silently fall back to localhost
+func checkSpeachesURLRequired() error {
+	if os.Getenv("REQUIRE_SPEACHES_URL") != "true" || os.Getenv("SPEACHES_URL") != "" {
+		return nil
+	}
+	return errors.New("SPEACHES_URL is not set and REQUIRE_SPEACHES_URL=true; set SPEACHES_URL to the speaches.ai server address")
+}
+
+// checkSelfBackend refuses to start when SPEACHES_URL points back at this
+// server's own listen address, which would make every request loop.
+// ALLOW_SELF_BACKEND=true downgrades the error to a warning.
+func checkSelfBackend(listenAddr string) error {
+	raw := os.Getenv("SPEACHES_URL")
+	if raw == "" {
+		return nil
+	}
+
+	backend, err := url.Parse(raw)
+	if err != nil || backend.Host == "" {
+		return nil
+	}
+
+	backendPort := backend.Port()
+	if backendPort == "" {
+		backendPort = "80"
+		if backend.Scheme == "https" {
+			backendPort = "443"
+		}
+	}
+
+	_, listenPort, err := net.SplitHostPort(listenAddr)
+	if err != nil || backendPort != listenPort || !isLocalHost(backend.Hostname()) {
+		return nil
+	}
+
+	msg := fmt.Sprintf("SPEACHES_URL %s points at this server's own listen address %s", raw, listenAddr)
+	if os.Getenv("ALLOW_SELF_BACKEND") == "true" {
+		log.Printf("WARNING: %s; continuing because ALLOW_SELF_BACKEND=true", msg)
+		return nil
+	}
+	return errors.New(msg + "; set ALLOW_SELF_BACKEND=true to start anyway")
+}
+
+// isLocalHost reports whether host names this machine, without doing DNS lookups
+func isLocalHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	if hostname, err := os.Hostname(); err == nil && strings.EqualFold(host, hostname) {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() || ip.IsUnspecified() {
+		return true
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleGetConfig returns the settings the front-end needs to configure itself,
+// such as the offered TTS models and each model's default voice
+func handleGetConfig(c *gin.Context) {
+	models := make([]ttsModel, len(ttsModels))
+	defaultVoices := make(map[string]string, len(ttsModels))
+	for i, m := range ttsModels {
+		// Report the effective default, which ALLOWED_VOICES may change
+		m.DefaultVoice = defaultVoice(m.ID)
+		models[i] = m
+		defaultVoices[m.ID] = m.DefaultVoice
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tts": gin.H{
+			"models":         models,
+			"default_model":  defaultTTSModel(),
+			"default_voices": defaultVoices,
+		},
+		"onboarding": onboardingStatus(c.Request.Context()),
+	})
+}
+
+// onboardingStatus reports which kinds of models are installed, so a fresh
+// deployment can point users to Add Models. It uses the installed-model cache,
+// and reports no first run while the backend can't be reached.
+func onboardingStatus(ctx context.Context) gin.H {
+	installed, err := modelRegistry.installedModels(ctx, speachesBaseURL(), false)
+	if err != nil {
+		return gin.H{
+			"backend_reachable": false,
+			"tts_installed":     nil,
+			"stt_installed":     nil,
+			"first_run":         false,
+		}
+	}
+
+	// Classify models as the models page does, by their registry type
+	types := modelRegistry.modelTypes(ctx, speachesBaseURL())
+	ttsInstalled, sttInstalled := false, false
+	for id := range installed {
+		if types.of(id) == "stt" {
+			sttInstalled = true
+		} else {
+			ttsInstalled = true
+		}
+	}
+	return gin.H{
+		"backend_reachable": true,
+		"tts_installed":     ttsInstalled,
+		"stt_installed":     sttInstalled,
+		"first_run":         !ttsInstalled && !sttInstalled,
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// corsAllowedHeaders are the request headers a cross-origin client may send
+	corsAllowedHeaders = "Content-Type, Authorization, Idempotency-Key, X-Request-ID, X-Timeout-Ms"
+
+	// corsExposedHeaders are the response headers a cross-origin client may read
+	corsExposedHeaders = "Content-Disposition, Retry-After, Idempotent-Replayed, X-Request-ID, X-Model-Downloaded, X-Downloaded-Model, X-TTS-Chunks, X-Audio-ID, X-Audio-URL, X-Audio-TTL, X-Audio-Expires, X-Cache"
+
+	// corsMaxAge is how long, in seconds, a browser may reuse a preflight result
+	corsMaxAge = "600"
+)
+
+// corsOrigins returns the origins allowed to call the API, from
+// SPEACHES_CORS_ORIGINS (comma-separated, "*" for any). nil leaves CORS off.
+func corsOrigins() map[string]bool {
+	var origins map[string]bool
+	for _, origin := range strings.Split(os.Getenv("SPEACHES_CORS_ORIGINS"), ",") {
+		origin = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+		if origin == "" {
+			continue
+		}
+		if origins == nil {
+			origins = map[string]bool{}
+		}
+		origins[origin] = true
+	}
+	return origins
+}
+
+// corsMiddleware adds CORS headers to API responses for the allowed origins
+// and answers their preflight requests
+func corsMiddleware(origins map[string]bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" || !isAPIPath(c.Request.URL.Path) || !(origins["*"] || origins[origin]) {
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Header("Vary", "Origin")
+		c.Header("Access-Control-Expose-Headers", corsExposedHeaders)
+
+		// Answer the preflight here; the routes have no OPTIONS handlers
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			c.Header("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+			c.Header("Access-Control-Allow-Headers", corsAllowedHeaders)
+			c.Header("Access-Control-Max-Age", corsMaxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
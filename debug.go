@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// debugEnabled reports whether DEBUG=true, which mounts the troubleshooting endpoints
+func debugEnabled() bool {
+	return os.Getenv("DEBUG") == "true"
+}
+
+// handleDebugRawModels returns the speaches.ai /v1/models response exactly as the backend sent it
+func handleDebugRawModels(c *gin.Context) {
+	baseURL := speachesBaseURL()
+	modelsURL := baseURL + "/v1/models"
+
+	resp, err := speachesGet(c.Request.Context(), modelsURL)
+	if err != nil {
+		jsonError(c, http.StatusServiceUnavailable, "speaches.ai server is not available")
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "failed to read server response")
+		return
+	}
+
+	// Pass the upstream status and content type through untouched
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	c.Data(resp.StatusCode, contentType, body)
+}
+
+// handleDebugResolve shows how a TTS model and voice resolve to the model ID
+// sent upstream, and whether that model is installed, without synthesizing
+func handleDebugResolve(c *gin.Context) {
+	model, voice := c.Query("model"), c.Query("voice")
+	passthrough := isPassthroughTTSModel(c.Request.Context(), model)
+	if err := validateTTSModel(model); err != nil && !passthrough {
+		jsonErrorCode(c, http.StatusBadRequest, errCodeUnknownModel, err.Error())
+		return
+	}
+
+	resolvedModel, resolvedVoice, actualModel := resolveTTSModel(model, voice)
+	if passthrough {
+		resolvedModel, resolvedVoice, actualModel = model, voice, model
+	}
+	response := gin.H{
+		"passthrough":     passthrough,
+		"requested_model": model,
+		"requested_voice": voice,
+		"model":           resolvedModel,
+		"voice":           resolvedVoice,
+		"actual_model":    actualModel,
+		"model_fallback":  model != "" && model != resolvedModel,
+		"voice_fallback":  voice != resolvedVoice,
+		"installed":       nil,
+	}
+
+	// Ask the backend directly rather than trust the installed-model cache
+	installed, err := fetchInstalledModels(c.Request.Context(), speachesBaseURL())
+	if err != nil {
+		response["error"] = "speaches.ai server is not available"
+	} else {
+		response["installed"] = installed[actualModel]
+	}
+
+	c.JSON(http.StatusOK, response)
+}
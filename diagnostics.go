@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// diagnosticsTimeout bounds the backend calls made by a diagnostics run
+const diagnosticsTimeout = 10 * time.Second
+
+// diagnosticCheck is the outcome of one provisioning check
+type diagnosticCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // pass, fail or skip
+	Detail string `json:"detail"`
+	Hint   string `json:"hint,omitempty"`
+}
+
+// runDiagnostics checks whether the deployment is provisioned correctly:
+// backend reachable, default models installed and default voices valid.
+// installed is nil when the backend could not be reached.
+func runDiagnostics(ctx context.Context, speachesBaseURL string) (checks []diagnosticCheck, installed map[string]bool) {
+	checks = []diagnosticCheck{}
+	add := func(check diagnosticCheck) {
+		checks = append(checks, check)
+	}
+
+	// Backend reachable
+	installed, err := fetchInstalledModels(ctx, speachesBaseURL)
+	if err != nil {
+		add(diagnosticCheck{
+			Name:   "backend_reachable",
+			Status: "fail",
+			Detail: fmt.Sprintf("cannot list models at %s: %v", redactURL(speachesBaseURL), err),
+			Hint:   "Start speaches.ai or point SPEACHES_URL at it",
+		})
+	} else {
+		add(diagnosticCheck{
+			Name:   "backend_reachable",
+			Status: "pass",
+			Detail: fmt.Sprintf("%s is up with %d installed model(s)", redactURL(speachesBaseURL), len(installed)),
+		})
+	}
+
+	// Default models installed (skipped when the backend is down)
+	_, _, ttsModelID := resolveTTSModel("", "")
+	modelChecks := []struct {
+		name, model, hint string
+	}{
+		{"default_tts_model_installed", ttsModelID, "Install it from the Add TTS Models page"},
+		{"default_stt_model_installed", defaultSTTModel, "Install it from the Add STT Models page"},
+	}
+	for _, mc := range modelChecks {
+		check := diagnosticCheck{Name: mc.name}
+		switch {
+		case installed == nil:
+			check.Status = "skip"
+			check.Detail = "backend is not reachable"
+		case installed[mc.model]:
+			check.Status = "pass"
+			check.Detail = mc.model + " is installed"
+		default:
+			check.Status = "fail"
+			check.Detail = mc.model + " is not installed"
+			check.Hint = mc.hint
+		}
+		add(check)
+	}
+
+	// Default voices valid for their model, as requests will resolve them
+	// with SPEACHES_DEFAULT_VOICE and ALLOWED_VOICES applied
+	for _, m := range ttsModels {
+		check := diagnosticCheck{Name: "default_voice_valid:" + m.ID}
+		voice := defaultVoice(m.ID)
+		switch {
+		case !ttsVoices[m.ID][voice]:
+			check.Status = "fail"
+			check.Detail = voice + " is not a known " + m.Family + " voice"
+			check.Hint = "Choose a default voice from GET /api/config for " + m.ID
+		case checkVoiceAllowed(m.ID, voice, false) != nil:
+			check.Status = "fail"
+			check.Detail = voice + " is not in ALLOWED_VOICES"
+			check.Hint = "Add a " + m.Family + " voice to ALLOWED_VOICES"
+		default:
+			check.Status = "pass"
+			check.Detail = voice + " is a known " + m.Family + " voice"
+		}
+		add(check)
+	}
+
+	return checks, installed
+}
+
+// diagnosticsOK reports whether no check failed
+func diagnosticsOK(checks []diagnosticCheck) bool {
+	for _, check := range checks {
+		if check.Status == "fail" {
+			return false
+		}
+	}
+	return true
+}
+
+// handleDiagnosticsFull runs the provisioning checks against the backend
+func handleDiagnosticsFull(c *gin.Context) {
+	baseURL := speachesBaseURL()
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), diagnosticsTimeout)
+	defer cancel()
+
+	checks, _ := runDiagnostics(ctx, baseURL)
+
+	// A failed check is a 503, so probes can act on the status alone
+	status, ok := http.StatusOK, diagnosticsOK(checks)
+	if !ok {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, gin.H{
+		"ok":     ok,
+		"checks": checks,
+	})
+}
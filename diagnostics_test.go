@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDiagnosticsDefaultVoices(t *testing.T) {
+	tests := []struct {
+		name          string
+		defaultVoice  string
+		allowedVoices string
+		want          map[string]string // check name to status
+	}{
+		{"built-in defaults", "", "", map[string]string{
+			"default_voice_valid:tts-1":       "pass",
+			"default_voice_valid:tts-1-piper": "pass",
+		}},
+		{"configured default", "af_bella", "", map[string]string{
+			"default_voice_valid:tts-1": "pass",
+		}},
+		{"allow list without piper voices", "", "af_heart", map[string]string{
+			"default_voice_valid:tts-1":       "pass",
+			"default_voice_valid:tts-1-piper": "fail",
+		}},
+		{"configured default not allowed", "af_bella", "af_heart", map[string]string{
+			"default_voice_valid:tts-1": "pass", // falls back to the first allowed voice
+		}},
+	}
+
+	// No backend: the model checks are skipped and only the voices are judged
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SPEACHES_DEFAULT_VOICE", tt.defaultVoice)
+			t.Setenv("ALLOWED_VOICES", tt.allowedVoices)
+			t.Setenv("SPEACHES_MAX_RETRIES", "0")
+
+			checks, _ := runDiagnostics(context.Background(), server.URL)
+			got := map[string]string{}
+			for _, check := range checks {
+				got[check.Name] = check.Status
+			}
+			for name, status := range tt.want {
+				if got[name] != status {
+					t.Errorf("%s = %q, want %q", name, got[name], status)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleDiagnosticsFullStatus(t *testing.T) {
+	t.Setenv("SPEACHES_MAX_RETRIES", "0")
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	t.Setenv("SPEACHES_URL", server.URL)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/diagnostics/full", nil)
+
+	handleDiagnosticsFull(c)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status with the backend down = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
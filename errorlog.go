@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxErrorRecords bounds how many failed requests are remembered
+	maxErrorRecords = 200
+
+	// errorRecordTTL is how long a failed request can be looked up
+	errorRecordTTL = time.Hour
+
+	// maxErrorBodyCapture bounds how much of an error response is kept for parsing
+	maxErrorBodyCapture = 4096
+)
+
+// errorRecord is the stored detail of one failed API request. It holds no
+// request input such as text or audio, only where and how it failed.
+type errorRecord struct {
+	RequestID      string    `json:"request_id"`
+	Time           time.Time `json:"time"`
+	Method         string    `json:"method"`
+	Path           string    `json:"path"`
+	Status         int       `json:"status"`
+	Code           string    `json:"code"`
+	Error          string    `json:"error,omitempty"`
+	UpstreamStatus int       `json:"upstream_status,omitempty"`
+	UpstreamPath   string    `json:"upstream_path,omitempty"`
+}
+
+// errorRing keeps the most recent failed requests, overwriting the oldest
+type errorRing struct {
+	mu      sync.Mutex
+	records []errorRecord
+	next    int
+}
+
+// recentErrors holds the failed API requests for /api/errors/:request_id
+var recentErrors = &errorRing{}
+
+func init() {
+	registerCache("errors", recentErrors.clear)
+}
+
+// add stores a record, replacing the oldest once the ring is full
+func (r *errorRing) add(record errorRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.records) < maxErrorRecords {
+		r.records = append(r.records, record)
+		return
+	}
+	r.records[r.next] = record
+	r.next = (r.next + 1) % maxErrorRecords
+}
+
+// find returns the newest unexpired record for a request id
+func (r *errorRing) find(requestID string) (errorRecord, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var found errorRecord
+	ok := false
+	for _, record := range r.records {
+		if record.RequestID != requestID || time.Since(record.Time) > errorRecordTTL {
+			continue
+		}
+		if !ok || record.Time.After(found.Time) {
+			found, ok = record, true
+		}
+	}
+	return found, ok
+}
+
+// clear forgets every record and returns how many there were
+func (r *errorRing) clear() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := len(r.records)
+	r.records, r.next = nil, 0
+	return n
+}
+
+// errorBodyWriter keeps the start of error responses so their message and
+// code can be recorded; successful responses, such as audio, are not copied
+type errorBodyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *errorBodyWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *errorBodyWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// capture appends data while the response is an error and under the cap
+func (w *errorBodyWriter) capture(data []byte) {
+	if w.Status() < 400 {
+		return
+	}
+	if room := maxErrorBodyCapture - w.body.Len(); room > 0 {
+		if len(data) > room {
+			data = data[:room]
+		}
+		w.body.Write(data)
+	}
+}
+
+// classifyError returns the machine-readable code of a failure: the code the
+// handler sent if any, otherwise one derived from where it failed
+func classifyError(code string, status, upstreamStatus int) string {
+	switch {
+	case code != "":
+		return code
+	case status == http.StatusGatewayTimeout:
+		return "timeout"
+	case status == http.StatusBadGateway || upstreamStatus >= 400:
+		return "upstream_error"
+	case status >= 500:
+		return "internal_error"
+	default:
+		return "client_error"
+	}
+}
+
+// requestIDMiddleware tags every request with an X-Request-ID, taken from the
+// client when it sends a usable one, logs its speaches.ai calls under it and
+// records failed API requests under it
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		c.Header(requestIDHeader, requestID)
+
+		// Upstream calls are logged under the request id through the trace
+		trace := &requestTrace{id: requestID}
+		c.Request = c.Request.WithContext(withRequestTrace(c.Request.Context(), trace))
+
+		if !isAPIPath(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		writer := &errorBodyWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status < 400 {
+			return
+		}
+
+		var body struct {
+			Error string `json:"error"`
+			Code  string `json:"code"`
+		}
+		json.Unmarshal(writer.body.Bytes(), &body)
+
+		upstreamPath, upstreamStatus := trace.upstream()
+		recentErrors.add(errorRecord{
+			RequestID:      requestID,
+			Time:           time.Now(),
+			Method:         c.Request.Method,
+			Path:           c.Request.URL.Path,
+			Status:         status,
+			Code:           classifyError(body.Code, status, upstreamStatus),
+			Error:          body.Error,
+			UpstreamStatus: upstreamStatus,
+			UpstreamPath:   upstreamPath,
+		})
+	}
+}
+
+// handleGetError returns the stored detail of a failed request, so a
+// maintainer can look up a failure a user reports by its request id
+func handleGetError(c *gin.Context) {
+	record, ok := recentErrors.find(c.Param("request_id"))
+	if !ok {
+		jsonError(c, http.StatusNotFound, "no error recorded for this request id")
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
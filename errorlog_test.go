@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		clientID string
+		keep     bool
+	}{
+		{"client id kept", "abc-123_x.y", true},
+		{"no id generated", "", false},
+		{"too long replaced", strings.Repeat("a", maxRequestIDLength+1), false},
+		{"header injection replaced", "abc\r\nSet-Cookie: x", false},
+		{"spaces replaced", "abc def", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			router := gin.New()
+			router.Use(requestIDMiddleware())
+			router.GET("/page", func(c *gin.Context) {
+				if trace := requestTraceFrom(c.Request.Context()); trace != nil {
+					seen = trace.id
+				}
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/page", nil)
+			if tt.clientID != "" {
+				req.Header.Set(requestIDHeader, tt.clientID)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			got := w.Header().Get(requestIDHeader)
+			if tt.keep && got != tt.clientID {
+				t.Errorf("%s = %q, want the client's %q", requestIDHeader, got, tt.clientID)
+			}
+			if !tt.keep && (got == tt.clientID || len(got) != 16) {
+				t.Errorf("%s = %q, want a generated 16-character id", requestIDHeader, got)
+			}
+			if seen != got {
+				t.Errorf("handler saw request id %q, response has %q", seen, got)
+			}
+		})
+	}
+}
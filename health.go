@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// healthCheckTimeout keeps the upstream probe short so a health check never hangs
+const healthCheckTimeout = 2 * time.Second
+
+// handleHealth reports whether speaches.ai is reachable, for liveness/readiness probes
+func handleHealth(c *gin.Context) {
+	baseURL := speachesBaseURL()
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	reachable := false
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/v1/models", nil)
+	if err == nil {
+		if resp, err := speachesClient.Do(req); err == nil {
+			resp.Body.Close()
+			reachable = resp.StatusCode == http.StatusOK
+		}
+	}
+	latency := time.Since(start).Milliseconds()
+
+	if !reachable {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":     "degraded",
+			"upstream":   "unreachable",
+			"latency_ms": latency,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "ok",
+		"upstream":   "reachable",
+		"latency_ms": latency,
+	})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// heroContent is the title and description shown in a page's hero section
+type heroContent struct {
+	Title       string
+	Description string
+}
+
+// defaultHeroes holds the built-in hero content for each page
+var defaultHeroes = map[string]heroContent{
+	"tts": {
+		Title:       "👄 Text-to-Speech",
+		Description: "Convert text to natural-sounding speech with multiple voices and models",
+	},
+	"stt": {
+		Title:       "👂 Speech-to-Text",
+		Description: "Convert speech to text with advanced transcription models",
+	},
+	"models": {
+		Title:       "📦 Installed Models",
+		Description: "View and manage installed models for text-to-speech and speech-to-text",
+	},
+	"add-tts-models": {
+		Title:       "📥 Add Text-to-Speech Models",
+		Description: "Browse and install TTS models from the speaches.ai registry",
+	},
+	"add-stt-models": {
+		Title:       "📥 Add Speech-to-Text Models",
+		Description: "Browse and install STT models from the speaches.ai registry",
+	},
+	"help": {
+		Title:       "❓ Help",
+		Description: "The API routes behind this UI, for scripting and integrations",
+	},
+}
+
+// heroFor returns the hero content for a page, applying any
+// HERO_<PAGE>_TITLE / HERO_<PAGE>_DESCRIPTION environment overrides
+// (e.g. HERO_TTS_TITLE, HERO_ADD_STT_MODELS_DESCRIPTION)
+func heroFor(page string) heroContent {
+	hero := defaultHeroes[page]
+
+	prefix := "HERO_" + strings.ToUpper(strings.ReplaceAll(page, "-", "_"))
+	if title := os.Getenv(prefix + "_TITLE"); title != "" {
+		hero.Title = title
+	}
+	if description := os.Getenv(prefix + "_DESCRIPTION"); description != "" {
+		hero.Description = description
+	}
+
+	return hero
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// idempotencyKeyTTL is how long a successful install is replayed for a repeated Idempotency-Key
+	idempotencyKeyTTL = time.Hour
+
+	// maxIdempotencyKeys bounds how many keys are remembered
+	maxIdempotencyKeys = 1000
+
+	// maxIdempotencyKeyLength bounds the length of an Idempotency-Key header
+	maxIdempotencyKeyLength = 255
+)
+
+// idempotentResult is the response to the first request with an Idempotency-Key
+type idempotentResult struct {
+	modelID string
+	done    chan struct{} // closed once status and body are set
+	status  int
+	body    gin.H
+	expires time.Time
+}
+
+// idempotencyStore remembers install results by Idempotency-Key
+type idempotencyStore struct {
+	mu      sync.Mutex
+	results map[string]*idempotentResult
+}
+
+// installIdempotency holds the keys sent to /api/models/install
+var installIdempotency = &idempotencyStore{results: map[string]*idempotentResult{}}
+
+// begin returns the result recorded for key, or registers a new one that the
+// caller must finish; created reports which
+func (s *idempotencyStore) begin(key, modelID string) (result *idempotentResult, created bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneLocked()
+	if result, ok := s.results[key]; ok {
+		return result, false
+	}
+
+	result = &idempotentResult{modelID: modelID, done: make(chan struct{})}
+	s.results[key] = result
+	return result, true
+}
+
+// finish records the response for key. Only successes are kept for replay, so
+// a retry after a failure installs again.
+func (s *idempotencyStore) finish(key string, result *idempotentResult, status int, body gin.H) {
+	s.mu.Lock()
+	result.status, result.body = status, body
+	result.expires = time.Now().Add(idempotencyKeyTTL)
+	if status >= 300 {
+		delete(s.results, key)
+	}
+	s.mu.Unlock()
+
+	close(result.done)
+}
+
+// pruneLocked drops expired keys, then the oldest finished ones while over
+// maxIdempotencyKeys; s.mu must be held
+func (s *idempotencyStore) pruneLocked() {
+	now := time.Now()
+	for key, result := range s.results {
+		if !result.expires.IsZero() && now.After(result.expires) {
+			delete(s.results, key)
+		}
+	}
+
+	for len(s.results) >= maxIdempotencyKeys {
+		oldestKey := ""
+		var oldest time.Time
+		for key, result := range s.results {
+			if !result.expires.IsZero() && (oldestKey == "" || result.expires.Before(oldest)) {
+				oldestKey, oldest = key, result.expires
+			}
+		}
+		if oldestKey == "" {
+			// Every key is still running; keep them rather than break a retry
+			return
+		}
+		delete(s.results, oldestKey)
+	}
+}
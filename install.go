@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// installTimeout caps the whole install, including retries and the model download itself
+	installTimeout = 30 * time.Minute
+
+	// defaultInstallJobRetention is how long finished install jobs stay listed
+	defaultInstallJobRetention = time.Hour
+
+	// installHeartbeatInterval is how often a streamed install reports that it is still running
+	installHeartbeatInterval = 2 * time.Second
+)
+
+// installFlight is an install that is still running; requests for the same model wait for it
+type installFlight struct {
+	done   chan struct{}
+	status int
+	err    error
+}
+
+var (
+	installFlightsMu sync.Mutex
+	installFlights   = map[string]*installFlight{}
+)
+
+// startInstall returns the running install of modelID, starting one on
+// speaches.ai, recorded as an install job, if there is none. The install runs
+// on its own context, capped at installTimeout, so a caller that disconnects
+// or times out does not cancel it for the others waiting on it.
+func startInstall(ctx context.Context, speachesBaseURL, modelID string) *installFlight {
+	installFlightsMu.Lock()
+	defer installFlightsMu.Unlock()
+
+	if flight, ok := installFlights[modelID]; ok {
+		return flight
+	}
+	flight := &installFlight{done: make(chan struct{})}
+	installFlights[modelID] = flight
+
+	// Keep the request's values, such as its trace, but not its cancellation
+	installCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), installTimeout)
+	go func() {
+		defer cancel()
+		flight.status, flight.err = installModel(installCtx, speachesBaseURL, modelID)
+
+		installFlightsMu.Lock()
+		delete(installFlights, modelID)
+		installFlightsMu.Unlock()
+		close(flight.done)
+	}()
+	return flight
+}
+
+// wait returns the result of the install, or a 504 once ctx is done; the
+// install itself keeps running
+func (f *installFlight) wait(ctx context.Context) (int, error) {
+	select {
+	case <-f.done:
+		return f.status, f.err
+	case <-ctx.Done():
+		return http.StatusGatewayTimeout, errors.New("timed out waiting for the install to finish; it is still running")
+	}
+}
+
+// runInstall installs a model on speaches.ai and waits for it until ctx is
+// done. A model that is already being installed is not requested again; the
+// caller waits for the running install and gets its result. On failure it
+// returns the HTTP status to report along with the error.
+func runInstall(ctx context.Context, speachesBaseURL, modelID string) (int, error) {
+	return startInstall(ctx, speachesBaseURL, modelID).wait(ctx)
+}
+
+// installErrorCode returns the error code of a failed install; speaches.ai
+// answers 404 for a model that is not in its registry
+func installErrorCode(status int) string {
+	if status == http.StatusNotFound {
+		return errCodeModelNotFound
+	}
+	return errorCodeForStatus(status)
+}
+
+// installResponse returns the status and body reporting an install's result
+func installResponse(status int, err error) (int, gin.H) {
+	if err != nil {
+		return status, gin.H{
+			"error": err.Error(),
+			"code":  installErrorCode(status),
+		}
+	}
+	return http.StatusOK, gin.H{
+		"success": true,
+		"message": "Model installed successfully",
+	}
+}
+
+// installModel sends one install to speaches.ai, recorded as an install job
+func installModel(ctx context.Context, speachesBaseURL, modelID string) (int, error) {
+	// URL for installing the model
+	installURL := speachesBaseURL + "/v1/models/" + url.PathEscape(modelID)
+
+	// Record the install for the jobs panel
+	jobID := installJobs.start(modelID)
+
+	// Make a POST request to install the model, retrying transient failures
+	resp, err := postInstall(ctx, installURL, modelID)
+	if err != nil {
+		installJobs.finish(jobID, err.Error())
+		if ctx.Err() != nil {
+			return http.StatusGatewayTimeout, errors.New("speaches.ai did not finish installing the model in time")
+		}
+		return http.StatusServiceUnavailable, errors.New("speaches.ai server is not available")
+	}
+	defer resp.Body.Close()
+
+	// Read the response body
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		installJobs.finish(jobID, "failed to read server response")
+		return http.StatusInternalServerError, errors.New("failed to read server response")
+	}
+
+	// Check if installation was successful
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		errorMsg := upstreamErrorMessage(bodyBytes)
+		installJobs.finish(jobID, errorMsg)
+		return resp.StatusCode, errors.New("Failed to install model: " + errorMsg)
+	}
+
+	installJobs.finish(jobID, "")
+	modelRegistry.invalidateInstalled()
+	return http.StatusOK, nil
+}
+
+// handleInstallModelStream installs the model given by ?model_id= and reports
+// on it as Server-Sent Events. speaches.ai does not report download progress,
+// so progress events are heartbeats with the elapsed time; the stream ends
+// with a done or error event once the upstream install returns.
+func handleInstallModelStream(c *gin.Context) {
+	modelID := c.Query("model_id")
+	if modelID == "" {
+		jsonError(c, http.StatusBadRequest, "model_id is required")
+		return
+	}
+
+	baseURL := speachesBaseURL()
+
+	// Leaving the page ends the stream; the install keeps running for anyone
+	// else waiting on it and still shows up in the jobs panel
+	ctx := c.Request.Context()
+
+	started := time.Now()
+	result := make(chan error, 1)
+	var status int // set before the error is sent on result
+	go func() {
+		var err error
+		status, err = runInstall(ctx, baseURL, modelID)
+		result <- err
+	}()
+
+	heartbeat := time.NewTicker(installHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("progress", gin.H{"model_id": modelID, "state": "started", "elapsed_s": 0})
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case err := <-result:
+			if err != nil {
+				c.SSEvent("error", gin.H{"model_id": modelID, "error": err.Error(), "code": installErrorCode(status)})
+			} else {
+				c.SSEvent("done", gin.H{"model_id": modelID, "message": "Model installed successfully"})
+			}
+			return false
+		case <-heartbeat.C:
+			c.SSEvent("progress", gin.H{
+				"model_id":  modelID,
+				"state":     "downloading",
+				"elapsed_s": int(time.Since(started).Seconds()),
+			})
+			return true
+		}
+	})
+}
+
+// markModelDownloaded tells the client that the request waited for a model
+// download, which explains its latency
+func markModelDownloaded(c *gin.Context, modelID string) {
+	if modelID == "" {
+		return
+	}
+	c.Header("X-Model-Downloaded", "true")
+	c.Header("X-Downloaded-Model", modelID)
+}
+
+// postInstall asks speaches.ai to download a model. Transient failures are
+// retried by retryTransient; any other response, including a definitive 4xx,
+// is returned to the caller as-is.
+func postInstall(ctx context.Context, installURL, modelID string) (*http.Response, error) {
+	resp, err := retryTransient(ctx, func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, installURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return speachesClient.Do(req)
+	})
+	if err != nil {
+		log.Printf("install %s: failed: %v", modelID, err)
+		return nil, err
+	}
+	log.Printf("install %s: returned %d", modelID, resp.StatusCode)
+	return resp, nil
+}
+
+// installJob records one model install requested through the UI
+type installJob struct {
+	ID         string     `json:"id"`
+	ModelID    string     `json:"model_id"`
+	State      string     `json:"state"` // running, done or failed
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// installJobTracker keeps recent install jobs for the jobs panel
+type installJobTracker struct {
+	mu     sync.Mutex
+	nextID int
+	jobs   map[string]*installJob
+}
+
+// installJobs tracks the installs started by handleInstallModel
+var installJobs = &installJobTracker{jobs: map[string]*installJob{}}
+
+// installJobRetention returns how long finished jobs are kept, overridable with INSTALL_JOB_RETENTION (e.g. "24h")
+func installJobRetention() time.Duration {
+	if retention, err := time.ParseDuration(os.Getenv("INSTALL_JOB_RETENTION")); err == nil && retention > 0 {
+		return retention
+	}
+	return defaultInstallJobRetention
+}
+
+// start records a new running job for modelID
+func (t *installJobTracker) start(modelID string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pruneLocked()
+
+	t.nextID++
+	id := strconv.Itoa(t.nextID)
+	t.jobs[id] = &installJob{
+		ID:        id,
+		ModelID:   modelID,
+		State:     "running",
+		StartedAt: time.Now(),
+	}
+	return id
+}
+
+// finish marks a job done, or failed when errMsg is not empty
+func (t *installJobTracker) finish(id, errMsg string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	job, ok := t.jobs[id]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	job.FinishedAt = &now
+	job.State = "done"
+	if errMsg != "" {
+		job.State = "failed"
+		job.Error = errMsg
+	}
+}
+
+// list returns the jobs in the given state (all when empty), oldest first,
+// after pruning finished jobs older than the retention period
+func (t *installJobTracker) list(state string) []installJob {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pruneLocked()
+
+	jobs := []installJob{}
+	for _, job := range t.jobs {
+		if state == "" || job.State == state {
+			jobs = append(jobs, *job)
+		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].StartedAt.Before(jobs[j].StartedAt)
+	})
+	return jobs
+}
+
+// pruneLocked drops finished jobs older than the retention period
+func (t *installJobTracker) pruneLocked() {
+	cutoff := time.Now().Add(-installJobRetention())
+	for id, job := range t.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(cutoff) {
+			delete(t.jobs, id)
+		}
+	}
+}
+
+// handleGetInstallJobs lists install jobs, optionally filtered with ?state=running|done|failed
+func handleGetInstallJobs(c *gin.Context) {
+	state := c.Query("state")
+	if state != "" && state != "running" && state != "done" && state != "failed" {
+		jsonError(c, http.StatusBadRequest, "state must be running, done or failed")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": installJobs.list(state)})
+}
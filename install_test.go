@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPostInstallRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		retries    string
+		statuses   []int // answered in turn; the last one repeats
+		wantStatus int
+		wantCalls  int32
+	}{
+		{"success", "2", []int{http.StatusCreated}, http.StatusCreated, 1},
+		{"gateway error retried", "2", []int{http.StatusBadGateway, http.StatusCreated}, http.StatusCreated, 2},
+		{"retries run out", "2", []int{http.StatusServiceUnavailable}, http.StatusServiceUnavailable, 3},
+		{"retries disabled", "0", []int{http.StatusBadGateway, http.StatusCreated}, http.StatusBadGateway, 1},
+		{"client error not retried", "2", []int{http.StatusNotFound, http.StatusCreated}, http.StatusNotFound, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SPEACHES_MAX_RETRIES", tt.retries)
+
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				call := int(calls.Add(1))
+				w.WriteHeader(tt.statuses[min(call, len(tt.statuses))-1])
+			}))
+			defer server.Close()
+
+			resp, err := postInstall(context.Background(), server.URL+"/v1/models/m", "m")
+			if err != nil {
+				t.Fatalf("postInstall: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("sent %d requests, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRunInstallOutlivesFirstCaller(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	first, cancel := context.WithCancel(context.Background())
+	flight := startInstall(first, server.URL, "m")
+	waiter := startInstall(context.Background(), server.URL, "m")
+	if waiter != flight {
+		t.Fatal("second install of the same model started a new flight")
+	}
+
+	// The first caller gives up while the download is still running
+	cancel()
+	if status, err := flight.wait(first); status != http.StatusGatewayTimeout || err == nil {
+		t.Errorf("canceled caller got %d, %v; want 504 and an error", status, err)
+	}
+
+	close(release)
+	if status, err := waiter.wait(context.Background()); status != http.StatusOK || err != nil {
+		t.Errorf("waiting caller got %d, %v; want 200 and no error", status, err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("sent %d installs, want 1", got)
+	}
+}
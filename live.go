@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// liveWindow is how much buffered audio is transcribed at a time
+	liveWindow = 5 * time.Second
+
+	// liveOverlap is repeated from the previous window so words on a boundary are not cut
+	liveOverlap = 500 * time.Millisecond
+
+	// liveMinTail is the shortest leftover audio still transcribed when a session ends
+	liveMinTail = 300 * time.Millisecond
+
+	// liveSessionIdle is how long a session may go without chunks before it is dropped
+	liveSessionIdle = 2 * time.Minute
+
+	// maxLiveSessions bounds the number of concurrent live sessions
+	maxLiveSessions = 20
+
+	// maxLiveBufferBytes bounds the audio a session holds that has not been transcribed yet
+	maxLiveBufferBytes = 50 << 20
+
+	// maxLiveTotalBytes bounds the audio all live sessions hold together, so
+	// maxLiveSessions full buffers can't take a gigabyte between them
+	maxLiveTotalBytes = 256 << 20
+
+	// defaultLiveSampleRate is assumed for raw PCM chunks that don't set sample_rate
+	defaultLiveSampleRate = 16000
+
+	// maxOverlapWords is how many repeated words are looked for where two windows meet
+	maxOverlapWords = 8
+)
+
+var (
+	errTooManyLiveSessions = errors.New("too many live sessions, try again later")
+	errLiveBufferFull      = errors.New("live session buffer is full")
+	errLiveCapacity        = errors.New("live transcription is buffering too much audio, try again later")
+)
+
+// liveSession buffers the audio of one live recording. Raw 16-bit mono PCM is
+// transcribed in overlapping windows as it arrives ("windowed"); any other
+// format cannot be cut safely, so it is transcribed as one file when the
+// session ends ("whole_file").
+type liveSession struct {
+	mu         sync.Mutex
+	mode       string
+	sampleRate int
+	language   string
+	model      string // upstream model ID, resolved when the session opens
+	filename   string
+	audio      []byte
+	next       int // offset of the first byte not transcribed yet (windowed mode)
+	transcript string
+	closed     bool // set under mu once the session is removed, so no chunk is added after that
+
+	// Guarded by the store's mu
+	updated  time.Time
+	reserved int // bytes of audio counted against maxLiveTotalBytes
+}
+
+// liveSessionStore holds the open live sessions
+type liveSessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*liveSession
+	bytes    int // audio held by all sessions
+}
+
+// liveSessions is the store behind /api/stt/live
+var liveSessions = &liveSessionStore{sessions: map[string]*liveSession{}}
+
+// create opens a session, dropping idle ones first
+func (s *liveSessionStore) create(session *liveSession) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneLocked()
+	if len(s.sessions) >= maxLiveSessions {
+		return "", errTooManyLiveSessions
+	}
+
+	id, err := newAudioID()
+	if err != nil {
+		return "", err
+	}
+	session.updated = time.Now()
+	s.sessions[id] = session
+	return id, nil
+}
+
+// get returns an open session and marks it as active
+func (s *liveSessionStore) get(id string) (*liveSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneLocked()
+	session, ok := s.sessions[id]
+	if ok {
+		session.updated = time.Now()
+	}
+	return session, ok
+}
+
+// reserve counts n more bytes of audio held by session against
+// maxLiveTotalBytes, failing when the budget is used up
+func (s *liveSessionStore) reserve(session *liveSession, n int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.bytes+n > maxLiveTotalBytes {
+		return errLiveCapacity
+	}
+	s.bytes += n
+	session.reserved += n
+	return nil
+}
+
+// release returns n bytes of audio session no longer holds to the budget
+func (s *liveSessionStore) release(session *liveSession, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n = min(n, session.reserved)
+	s.bytes -= n
+	session.reserved -= n
+}
+
+// remove closes a session and frees its audio; session.mu must be held
+func (s *liveSessionStore) remove(id string, session *liveSession) {
+	session.closed = true
+	session.audio = nil
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropLocked(id, session)
+}
+
+// dropLocked forgets a session and returns its audio to the budget; s.mu must be held
+func (s *liveSessionStore) dropLocked(id string, session *liveSession) {
+	if s.sessions[id] == session {
+		delete(s.sessions, id)
+	}
+	s.bytes -= session.reserved
+	session.reserved = 0
+}
+
+// pruneLocked drops sessions that have been idle too long. A session a
+// request is still working on is not idle and is skipped; s.mu must be held.
+func (s *liveSessionStore) pruneLocked() {
+	cutoff := time.Now().Add(-liveSessionIdle)
+	for id, session := range s.sessions {
+		if session.updated.Before(cutoff) && session.mu.TryLock() {
+			session.closed = true
+			session.audio = nil
+			s.dropLocked(id, session)
+			session.mu.Unlock()
+		}
+	}
+}
+
+// durationBytes returns the length of d in 16-bit mono PCM at sampleRate
+func durationBytes(d time.Duration, sampleRate int) int {
+	return int(d.Seconds()*float64(sampleRate)) * 2
+}
+
+// pcmToWAV wraps 16-bit mono little-endian PCM in a WAV header
+func pcmToWAV(pcm []byte, sampleRate int) []byte {
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	binary.Write(&out, binary.LittleEndian, uint32(36+len(pcm)))
+	out.WriteString("WAVEfmt ")
+	binary.Write(&out, binary.LittleEndian, uint32(16))           // fmt chunk size
+	binary.Write(&out, binary.LittleEndian, uint16(1))            // PCM
+	binary.Write(&out, binary.LittleEndian, uint16(1))            // mono
+	binary.Write(&out, binary.LittleEndian, uint32(sampleRate))   // sample rate
+	binary.Write(&out, binary.LittleEndian, uint32(sampleRate*2)) // byte rate
+	binary.Write(&out, binary.LittleEndian, uint16(2))            // block align
+	binary.Write(&out, binary.LittleEndian, uint16(16))           // bits per sample
+	out.WriteString("data")
+	binary.Write(&out, binary.LittleEndian, uint32(len(pcm)))
+	out.Write(pcm)
+	return out.Bytes()
+}
+
+// mergeOverlap joins the text of consecutive windows, dropping the words the
+// overlap made the backend transcribe twice
+func mergeOverlap(prev, next string) string {
+	next = strings.TrimSpace(next)
+	if prev == "" || next == "" {
+		return prev + next
+	}
+
+	normalize := func(word string) string {
+		return strings.ToLower(strings.TrimFunc(word, func(r rune) bool {
+			return unicode.IsPunct(r)
+		}))
+	}
+	prevWords := strings.Fields(prev)
+	nextWords := strings.Fields(next)
+
+	for n := min(maxOverlapWords, len(prevWords), len(nextWords)); n > 0; n-- {
+		match := true
+		for i := 0; i < n; i++ {
+			if normalize(prevWords[len(prevWords)-n+i]) != normalize(nextWords[i]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			nextWords = nextWords[n:]
+			break
+		}
+	}
+
+	if len(nextWords) == 0 {
+		return prev
+	}
+	return prev + " " + strings.Join(nextWords, " ")
+}
+
+// liveTranscribe sends one piece of a live session to speaches.ai and returns
+// its text, or the HTTP status and error to report on failure
+func liveTranscribe(ctx context.Context, speachesBaseURL string, session *liveSession, filename string, audio []byte) (string, int, *errorResponse) {
+	params := sttParams{Language: session.language, Model: session.model}
+	resp, _, err := postTranscription(ctx, speachesBaseURL, filename, bytesAudio(audio), params)
+	if err != nil {
+		status, failure := upstreamCallError(err)
+		return "", status, &failure
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		failure := upstreamError(bodyBytes)
+		return "", resp.StatusCode, &failure
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", http.StatusInternalServerError, &errorResponse{Error: "failed to decode transcription response", Code: errCodeInternal}
+	}
+	return result.Text, http.StatusOK, nil
+}
+
+// handleSTTLive accepts a recording in chunks and returns text as it becomes
+// available. The first chunk opens a session; later chunks pass its id in
+// the session field, and the last one sets final=true.
+func handleSTTLive(c *gin.Context) {
+	if !parseUploadForm(c, 1) {
+		return
+	}
+
+	final := c.PostForm("final") == "true"
+
+	sessionID := c.PostForm("session")
+	var session *liveSession
+	if sessionID == "" {
+		language, err := parseSTTLanguage(c.DefaultPostForm("language", defaultSTTLanguage))
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Map the quality tier or model ID to the model sent upstream, as /api/stt does
+		model, err := resolveSTTModel(c.Request.Context(), speachesBaseURL(), strings.TrimSpace(c.DefaultPostForm("model", "standard")))
+		if err != nil {
+			jsonErrorCode(c, http.StatusBadRequest, errCodeUnknownModel, err.Error())
+			return
+		}
+
+		session = &liveSession{mode: "whole_file", language: language, model: model}
+		if c.PostForm("format") == "pcm" {
+			session.mode = "windowed"
+			session.sampleRate = defaultLiveSampleRate
+			if raw := c.PostForm("sample_rate"); raw != "" {
+				rate, err := strconv.Atoi(raw)
+				if err != nil || rate < 8000 || rate > 48000 {
+					jsonError(c, http.StatusBadRequest, "sample_rate must be an integer from 8000 to 48000")
+					return
+				}
+				session.sampleRate = rate
+			}
+		}
+
+		id, err := liveSessions.create(session)
+		if err != nil {
+			if errors.Is(err, errTooManyLiveSessions) {
+				jsonErrorCode(c, http.StatusServiceUnavailable, errCodeUnavailable, err.Error())
+				return
+			}
+			jsonError(c, http.StatusInternalServerError, "failed to create live session")
+			return
+		}
+		sessionID = id
+	} else {
+		var ok bool
+		session, ok = liveSessions.get(sessionID)
+		if !ok {
+			jsonError(c, http.StatusNotFound, "live session not found or expired")
+			return
+		}
+	}
+
+	// Chunks of a session are handled one at a time, in the order they arrive.
+	// The session may have been closed meanwhile by its final chunk or as idle.
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.closed {
+		jsonError(c, http.StatusNotFound, "live session not found or expired")
+		return
+	}
+
+	// The final request may come without audio
+	if file, err := c.FormFile("audio"); err == nil {
+		if !checkUploadSize(c, file) {
+			return
+		}
+
+		src, err := file.Open()
+		if err != nil {
+			jsonError(c, http.StatusInternalServerError, "failed to open audio file")
+			return
+		}
+		chunk, err := io.ReadAll(src)
+		src.Close()
+		if err != nil {
+			jsonError(c, http.StatusInternalServerError, "failed to read audio file")
+			return
+		}
+
+		// A whole-file recording gets the type and allowlist checks of /api/stt
+		// from its first chunk, which holds the container header
+		if session.mode == "whole_file" && len(session.audio) == 0 {
+			if status, err := checkSTTAudio(chunk[:min(len(chunk), 512)]); err != nil {
+				liveSessions.remove(sessionID, session)
+				jsonError(c, status, err.Error())
+				return
+			}
+		}
+
+		if len(session.audio)-session.next+len(chunk) > maxLiveBufferBytes {
+			liveSessions.remove(sessionID, session)
+			jsonError(c, http.StatusRequestEntityTooLarge, errLiveBufferFull.Error())
+			return
+		}
+		if err := liveSessions.reserve(session, len(chunk)); err != nil {
+			jsonErrorCode(c, http.StatusServiceUnavailable, errCodeUnavailable, err.Error())
+			return
+		}
+		if session.filename == "" {
+			session.filename = sanitizeFilename(file.Filename, "audio")
+		}
+		session.audio = append(session.audio, chunk...)
+	}
+
+	baseURL := speachesBaseURL()
+
+	ctx, cancel := upstreamContext(c)
+	defer cancel()
+
+	fail := func(status int, failure *errorResponse) {
+		setRetryAfter(c, failure.Code)
+		response := gin.H{"error": failure.Error, "code": failure.Code, "session": sessionID}
+		if failure.Upstream != nil {
+			response["upstream"] = failure.Upstream
+		}
+		c.JSON(status, response)
+	}
+
+	before := session.transcript
+	switch session.mode {
+	case "windowed":
+		windowBytes := durationBytes(liveWindow, session.sampleRate)
+		overlapBytes := durationBytes(liveOverlap, session.sampleRate)
+
+		// Transcribe every full window, plus what is left once the recording ends
+		for {
+			pending := len(session.audio) - session.next
+			if pending < windowBytes && (!final || pending < durationBytes(liveMinTail, session.sampleRate)) {
+				break
+			}
+
+			// Samples are two bytes, so never end a window on half of one
+			end := session.next + min(pending, windowBytes)&^1
+			start := max(session.next-overlapBytes, 0)
+			text, status, failure := liveTranscribe(ctx, baseURL, session, "live.wav", pcmToWAV(session.audio[start:end], session.sampleRate))
+			if failure != nil {
+				fail(status, failure)
+				return
+			}
+			session.transcript = mergeOverlap(session.transcript, text)
+			session.next = end
+
+			// Keep only the overlap of audio that has been transcribed
+			if drop := session.next - overlapBytes; drop > 0 {
+				session.audio = session.audio[drop:]
+				session.next -= drop
+				liveSessions.release(session, drop)
+			}
+		}
+	default:
+		if final && len(session.audio) > 0 {
+			// Convert formats the backend may not accept, as /api/stt does
+			filename, audio := session.filename, session.audio
+			if needsTranscode(filename) {
+				wav, err := transcodeToWAV(ctx, bytes.NewReader(audio))
+				if err != nil {
+					liveSessions.remove(sessionID, session)
+					jsonError(c, http.StatusUnprocessableEntity, "failed to transcode audio: "+err.Error())
+					return
+				}
+				filename, audio = strings.TrimSuffix(filename, path.Ext(filename))+".wav", wav
+			}
+
+			text, status, failure := liveTranscribe(ctx, baseURL, session, filename, audio)
+			if failure != nil {
+				fail(status, failure)
+				return
+			}
+			session.transcript = strings.TrimSpace(text)
+		}
+	}
+
+	if final {
+		liveSessions.remove(sessionID, session)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session":    sessionID,
+		"mode":       session.mode,
+		"text":       strings.TrimSpace(strings.TrimPrefix(session.transcript, before)),
+		"transcript": session.transcript,
+		"final":      final,
+	})
+}
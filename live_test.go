@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLiveSessionBudget(t *testing.T) {
+	store := &liveSessionStore{sessions: map[string]*liveSession{}}
+	first, second := &liveSession{}, &liveSession{}
+	firstID, _ := store.create(first)
+	secondID, _ := store.create(second)
+
+	if err := store.reserve(first, maxLiveTotalBytes-10); err != nil {
+		t.Fatalf("reserve within the budget: %v", err)
+	}
+	if err := store.reserve(second, 20); !errors.Is(err, errLiveCapacity) {
+		t.Fatalf("reserve over the budget = %v, want %v", err, errLiveCapacity)
+	}
+
+	// Transcribed audio and closed sessions give their bytes back
+	store.release(first, 100)
+	if err := store.reserve(second, 20); err != nil {
+		t.Fatalf("reserve after a release: %v", err)
+	}
+	first.mu.Lock()
+	store.remove(firstID, first)
+	first.mu.Unlock()
+	second.mu.Lock()
+	store.remove(secondID, second)
+	second.mu.Unlock()
+	if store.bytes != 0 {
+		t.Errorf("store holds %d bytes after every session closed, want 0", store.bytes)
+	}
+	if !first.closed || !second.closed {
+		t.Error("removed sessions are not marked closed")
+	}
+}
+
+func TestLiveSessionPruneSkipsBusySessions(t *testing.T) {
+	store := &liveSessionStore{sessions: map[string]*liveSession{}}
+	idle, busy := &liveSession{}, &liveSession{}
+	idleID, _ := store.create(idle)
+	busyID, _ := store.create(busy)
+	store.reserve(idle, 10)
+	store.reserve(busy, 10)
+
+	past := time.Now().Add(-2 * liveSessionIdle)
+	idle.updated, busy.updated = past, past
+
+	// A request is still working on busy
+	busy.mu.Lock()
+	store.mu.Lock()
+	store.pruneLocked()
+	store.mu.Unlock()
+	busy.mu.Unlock()
+
+	if _, ok := store.sessions[idleID]; ok || !idle.closed {
+		t.Error("idle session was not pruned and closed")
+	}
+	if _, ok := store.sessions[busyID]; !ok || busy.closed {
+		t.Error("busy session was pruned")
+	}
+	if store.bytes != 10 {
+		t.Errorf("store holds %d bytes, want the busy session's 10", store.bytes)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultModelLoadRetryTimeout is how long a request keeps retrying while the backend loads a model
+	defaultModelLoadRetryTimeout = 30 * time.Second
+
+	// Backoff between retries while a model is loading
+	modelLoadInitialBackoff = 500 * time.Millisecond
+	modelLoadMaxBackoff     = 5 * time.Second
+)
+
+// errModelLoading is returned when the backend is still loading a model after the retry window
+var errModelLoading = errors.New("model is loading, try again shortly")
+
+// modelLoadRetryTimeout returns the retry window, overridable with MODEL_LOAD_RETRY_TIMEOUT (e.g. "1m", "0" disables)
+func modelLoadRetryTimeout() time.Duration {
+	value := os.Getenv("MODEL_LOAD_RETRY_TIMEOUT")
+	if value == "0" {
+		return 0
+	}
+	if timeout, err := time.ParseDuration(value); err == nil && timeout > 0 {
+		return timeout
+	}
+	return defaultModelLoadRetryTimeout
+}
+
+// isModelLoading reports whether a response is the transient 503 a backend sends
+// while a model is loaded into memory. The body stays readable for the caller.
+func isModelLoading(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		return false
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	return strings.Contains(strings.ToLower(upstreamErrorMessage(body)), "loading")
+}
+
+// retryAfter returns the delay a response asks for in its Retry-After header, or 0
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// retryWhileLoading calls send until the backend stops answering "model
+// loading", backing off between attempts. It gives up with errModelLoading once
+// the retry window would be exceeded.
+func retryWhileLoading(ctx context.Context, send func() (*http.Response, error)) (*http.Response, error) {
+	deadline := time.Now().Add(modelLoadRetryTimeout())
+	backoff := modelLoadInitialBackoff
+
+	for {
+		resp, err := send()
+		if err != nil || !isModelLoading(resp) {
+			return resp, err
+		}
+		resp.Body.Close()
+
+		wait := backoff
+		if requested := retryAfter(resp); requested > 0 {
+			wait = min(requested, modelLoadMaxBackoff)
+		}
+		if time.Now().Add(wait).After(deadline) {
+			return nil, errModelLoading
+		}
+
+		log.Printf("speaches.ai is loading a model, retrying in %s", wait)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, modelLoadMaxBackoff)
+	}
+}
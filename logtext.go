@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"strconv"
+)
+
+// defaultLogTextMaxLen is how many characters of user text are logged when LOG_TEXT_MAXLEN is unset
+const defaultLogTextMaxLen = 200
+
+// logTextMaxLen returns the logged text limit, overridable with LOG_TEXT_MAXLEN ("0" logs no text at all)
+func logTextMaxLen() int {
+	value := os.Getenv("LOG_TEXT_MAXLEN")
+	if value == "0" {
+		return 0
+	}
+	if maxLen, err := strconv.Atoi(value); err == nil && maxLen > 0 {
+		return maxLen
+	}
+	return defaultLogTextMaxLen
+}
+
+// truncateForLog shortens user text, or upstream messages that may echo it,
+// before it is logged. Only the recorded copy is cut; the text sent to the
+// backend is never changed.
+func truncateForLog(text string) string {
+	maxLen := logTextMaxLen()
+	runes := []rune(text)
+	if len(runes) <= maxLen {
+		return text
+	}
+	return string(runes[:maxLen]) + "…"
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamableFormats are the TTS formats whose chunks concatenate into one playable track:
+// mp3 frames can be appended back-to-back, raw PCM trivially, and WAV once the
+// per-chunk headers are replaced with a single streaming header.
+var streamableFormats = map[string]bool{
+	"mp3": true,
+	"wav": true,
+	"pcm": true,
+}
+
+// handleTTSLong synthesizes long text chunk by chunk and streams the results
+// back-to-back as a single continuous audio track
+func handleTTSLong(c *gin.Context) {
+	var req ttsRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		jsonError(c, http.StatusBadRequest, "text field is required")
+		return
+	}
+
+	// Whitespace-only text would only synthesize silence
+	req.Text = normalizeTTSText(req.Text)
+	if req.Text == "" {
+		jsonErrorCode(c, http.StatusBadRequest, errCodeEmptyInput, "text cannot be empty")
+		return
+	}
+
+	// A per-request autodownload overrides AUTO_DOWNLOAD
+	c.Request = c.Request.WithContext(withAutoDownload(c.Request.Context(), req.AutoDownload))
+
+	if err := validateSpeed(req.Speed); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Chunking would cut SSML elements apart
+	if err := validateInputFormat(req.InputFormat); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.InputFormat == "ssml" {
+		jsonError(c, http.StatusBadRequest, "ssml input can't be split into chunks; use /api/tts")
+		return
+	}
+
+	// Installed models other than the two aliases are sent upstream as they are
+	passthrough := isPassthroughTTSModel(c.Request.Context(), req.Model)
+	if err := validateTTSModel(req.Model); err != nil && !passthrough {
+		jsonErrorCode(c, http.StatusBadRequest, errCodeUnknownModel, err.Error())
+		return
+	}
+
+	if err := checkVoiceAllowed(req.Model, req.Voice, passthrough); err != nil {
+		jsonErrorCode(c, http.StatusForbidden, errCodeVoiceNotAllowed, err.Error())
+		return
+	}
+
+	opts := req.options()
+	if passthrough {
+		opts.Model, opts.Voice, opts.ActualModel = req.Model, req.Voice, req.Model
+	}
+	if !streamableFormats[opts.Format] {
+		jsonError(c, http.StatusBadRequest, "format "+opts.Format+" cannot be streamed as one track; use mp3, wav or pcm")
+		return
+	}
+
+	if req.Manifest {
+		handleTTSLongManifest(c, opts, req.Text)
+		return
+	}
+
+	chunks := splitTextIntoChunks(req.Text, ttsChunkMaxChars())
+	if len(chunks) == 0 {
+		jsonErrorCode(c, http.StatusBadRequest, errCodeEmptyInput, "text cannot be empty")
+		return
+	}
+
+	baseURL := speachesBaseURL()
+
+	ctx, cancel := upstreamContext(c)
+	defer cancel()
+
+	done := activeStreams.start()
+	defer done()
+
+	for i, chunk := range chunks {
+		jsonPayload, err := opts.payload(chunk.Text)
+		if err != nil {
+			jsonError(c, http.StatusInternalServerError, "failed to marshal request")
+			return
+		}
+
+		resp, downloaded, err := postSpeech(ctx, baseURL, opts.Model, opts.Voice, jsonPayload)
+		if err != nil || isSpeechError(resp) {
+			status, failure := chunkFailure(resp, err)
+
+			// Once audio has been sent the status can no longer change, so just end the stream
+			if i > 0 {
+				log.Printf("long TTS: chunk %d/%d failed, ending stream early: %s", i+1, len(chunks), truncateForLog(failure.Error))
+				return
+			}
+			writeErrorResponse(c, status, failure)
+			return
+		}
+
+		if i == 0 {
+			markModelDownloaded(c, downloaded)
+			c.Header("Content-Type", ttsFormats[opts.Format])
+			c.Header("Content-Disposition", speechDisposition(req, opts.Format))
+			c.Header("X-TTS-Chunks", strconv.Itoa(len(chunks)))
+			c.Status(http.StatusOK)
+		}
+
+		audioBody := watchStall(resp.Body, cancel)
+		if opts.Format == "wav" {
+			err = copyWAVChunk(c.Writer, audioBody, i == 0)
+		} else {
+			_, err = io.Copy(c.Writer, audioBody)
+		}
+		resp.Body.Close()
+		if err != nil {
+			log.Printf("long TTS: chunk %d/%d failed, ending stream early: %v", i+1, len(chunks), err)
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// chunkFailure describes a failed chunk synthesis as a status and error
+// response. It closes resp if there is one.
+func chunkFailure(resp *http.Response, err error) (int, errorResponse) {
+	if err != nil {
+		return upstreamCallError(err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	return speechErrorStatus(resp), upstreamError(body)
+}
+
+// copyWAVChunk copies the PCM data of a WAV stream. For the first chunk it
+// writes a streaming header (RIFF and data sizes of 0xFFFFFFFF, as the total
+// length is unknown) built from the chunk's own format block; later chunks
+// only contribute their samples.
+func copyWAVChunk(w io.Writer, r io.Reader, writeHeader bool) error {
+	subchunks, err := readWAVHeader(r)
+	if err != nil {
+		return err
+	}
+
+	if writeHeader {
+		if _, err := w.Write(wavHeader(subchunks, 0xFFFFFFFF, 0xFFFFFFFF)); err != nil {
+			return err
+		}
+	}
+
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// readWAVHeader reads a WAV stream up to its sample data and returns the
+// sub-chunks (fmt, etc.) preceding it
+func readWAVHeader(r io.Reader) ([]byte, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, err
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, errors.New("upstream audio is not a WAV stream")
+	}
+
+	var header bytes.Buffer
+	for {
+		var chunkHeader [8]byte
+		if _, err := io.ReadFull(r, chunkHeader[:]); err != nil {
+			return nil, err
+		}
+		id := string(chunkHeader[0:4])
+		size := binary.LittleEndian.Uint32(chunkHeader[4:8])
+
+		if id == "data" {
+			return header.Bytes(), nil
+		}
+
+		// Chunks are padded to an even length
+		body := make([]byte, int64(size)+int64(size%2))
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, err
+		}
+		header.Write(chunkHeader[:])
+		header.Write(body)
+	}
+}
+
+// wavHeader builds a WAV header from the sub-chunks of readWAVHeader, with the
+// given RIFF and data sizes
+func wavHeader(subchunks []byte, riffSize, dataSize uint32) []byte {
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	binary.Write(&out, binary.LittleEndian, riffSize)
+	out.WriteString("WAVE")
+	out.Write(subchunks)
+	out.WriteString("data")
+	binary.Write(&out, binary.LittleEndian, dataSize)
+	return out.Bytes()
+}
+
+// wavByteRate returns the bytes per second declared by the fmt sub-chunk, or 0 if there is none
+func wavByteRate(subchunks []byte) int {
+	for len(subchunks) >= 8 {
+		id := string(subchunks[0:4])
+		size := int(binary.LittleEndian.Uint32(subchunks[4:8]))
+		body := subchunks[8:]
+		if id == "fmt " && len(body) >= 12 {
+			return int(binary.LittleEndian.Uint32(body[8:12]))
+		}
+		if size+size%2 > len(body) {
+			return 0
+		}
+		subchunks = body[size+size%2:]
+	}
+	return 0
+}
@@ -2,17 +2,20 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"embed"
 	_ "embed"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"html/template"
 	"io"
 	"io/fs"
-	"mime/multipart"
+	"log"
+	"mime"
 	"net/http"
-	"os"
+	"net/url"
 	"strings"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
 )
@@ -22,29 +25,95 @@ var webAssets embed.FS
 
 // TemplateData holds common data passed to all templates
 type TemplateData struct {
-	Title            string
-	Page             string
-	HeroTitle        string
-	HeroDescription  string
-	ContentID        string
-	ScriptFile       string
+	Title           string
+	Page            string
+	HeroTitle       string
+	HeroDescription string
+	ContentID       string
+	ScriptFile      string
+	Version         string
 }
 
-var templates *template.Template
+// listenAddr is the address the HTTP server listens on
+const listenAddr = ":5420"
+
+// templates holds the parsed page templates. It is an atomic pointer so a
+// reloaded set can be swapped in while requests are rendering the old one.
+var templates atomic.Pointer[template.Template]
+
+// loadTemplates parses all page templates from the embedded filesystem
+func loadTemplates() (*template.Template, error) {
+	return template.ParseFS(webAssets, "templates/base.html", "templates/tts.html", "templates/stt.html", "templates/models.html", "templates/add-tts-models.html", "templates/add-stt-models.html", "templates/error.html", "templates/help.html", "templates/partials.html")
+}
 
 func init() {
 	// Load all templates from embedded filesystem
-	var err error
-	templates, err = template.ParseFS(webAssets, "templates/base.html", "templates/tts.html", "templates/stt.html", "templates/models.html", "templates/add-tts-models.html", "templates/add-stt-models.html")
+	tmpl, err := loadTemplates()
 	if err != nil {
 		panic("Failed to load templates: " + err.Error())
 	}
+	templates.Store(tmpl)
 }
 
 func main() {
+	// Refuse the localhost fallback when the deployment must configure SPEACHES_URL
+	if err := checkSpeachesURLRequired(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Catch a SPEACHES_URL that isn't a usable URL
+	if err := checkSpeachesURL(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Log the embedded assets checksum and catch a corrupt build
+	if err := checkEmbeddedAssets(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Catch a default TTS model or voice that doesn't exist
+	if err := checkTTSDefaults(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Catch a malformed MODEL_TYPE_OVERRIDES
+	if err := checkModelTypeOverrides(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Catch a SPEACHES_URL that points back at this server
+	if err := checkSelfBackend(listenAddr); err != nil {
+		log.Fatal(err)
+	}
+
+	// Look up ffmpeg for optional STT transcoding
+	detectFFmpeg()
+
+	// Expire shared audio in the background when sharing is enabled
+	if shareEnabled() {
+		startShareJanitor()
+	}
+
 	// Create a new Gin router with default middleware
 	router := gin.Default()
 
+	// Redirect /stt/ to /stt and answer wrong methods with 405 instead of 404
+	router.RedirectTrailingSlash = true
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(handleNoRoute)
+	router.NoMethod(handleNoMethod)
+
+	// Keep at most one upload's worth of a multipart body in memory; the rest spills to disk
+	router.MaxMultipartMemory = maxUploadBytes()
+
+	// Tag requests with an X-Request-ID and remember failed API requests by it
+	router.Use(requestIDMiddleware())
+
+	// Let the configured origins call the API from the browser (off by default)
+	if origins := corsOrigins(); origins != nil {
+		router.Use(corsMiddleware(origins))
+	}
+
 	// Serve static files from embedded filesystem at /assets/
 	// Use fs.Sub to serve from assets/ subdirectory
 	assetsFS, _ := fs.Sub(webAssets, "assets")
@@ -65,86 +134,101 @@ func main() {
 	// Serve the add STT models page
 	router.GET("/add-stt-models", serveAddSTTModels)
 
-	// TTS endpoint that calls speaches.ai server
-	router.POST("/api/tts", handleTTS)
-
-	// STT endpoint for speech-to-text requests
-	router.POST("/api/stt", handleSTT)
-
-	// Models endpoint for listing installed models
-	router.GET("/api/models", handleGetModels)
-
-	// Models endpoint for fetching registry models
-	router.GET("/api/models/registry", handleGetRegistryModels)
-
-	// Models endpoint for installing models
-	router.POST("/api/models/install", handleInstallModel)
+	// Serve the help page listing the API routes
+	router.GET("/help", serveHelp)
+
+	// API routes are mounted through the registry so /api/routes can describe them
+	api := newRouteRegistry(router)
+
+	api.handle(http.MethodGet, "/healthz", "Liveness/readiness probe reporting speaches.ai reachability", handleHealth)
+	api.handle(http.MethodGet, "/version", "Build information for the running UI", handleVersion)
+	api.handle(http.MethodGet, "/api/routes", "This list of API routes", handleGetRoutes)
+	api.handle(http.MethodGet, "/audio/:id", `Shared audio created by /api/tts with "share": true`, handleGetSharedAudio)
+
+	api.handle(http.MethodGet, "/partials/voices", "Voice dropdown options of a TTS model as an HTML fragment", handleVoiceOptionsPartial)
+	api.handle(http.MethodGet, "/partials/models", "Installed TTS or STT models as an HTML fragment", handleModelsListPartial)
+	api.handle(http.MethodGet, "/partials/install-button", "Install button or installed badge of a model as an HTML fragment", handleInstallButtonPartial)
+
+	api.handle(http.MethodGet, "/api/config", "TTS models and default voices for the front end", handleGetConfig)
+	api.handle(http.MethodGet, "/api/voices", "Known voices of each TTS model, grouped by locale and gender", handleGetVoices)
+	api.handle(http.MethodGet, "/api/voices/samples", "A preview sentence in each voice's language", handleGetVoiceSamples)
+	api.handle(http.MethodGet, "/api/voices/preview", "A voice's preview sentence as cached MP3 audio", handleVoicePreview)
+	api.handle(http.MethodGet, "/api/voices/benchmark", "Round-trip synthesis latency of each voice", handleVoicesBenchmark)
+	api.handle(http.MethodGet, "/api/voices/catalog", "Built-in voice sets of each TTS model family", handleGetVoiceCatalog)
+
+	api.handle(http.MethodPost, "/api/tts", "Synthesize speech from text", handleTTS)
+	api.handle(http.MethodPost, "/api/tts/models-compare", "Synthesize the same text with several models for A/B listening", handleTTSModelsCompare)
+	api.handle(http.MethodPost, "/api/tts/chunks", "Preview how long text is split into synthesis chunks", handleTTSChunks)
+	api.handle(http.MethodPost, "/api/tts/long", "Synthesize long text as one continuous streamed track", handleTTSLong)
+
+	api.handle(http.MethodPost, "/api/stt", "Transcribe an audio file, optionally as SRT or VTT subtitles", handleSTT)
+	api.handle(http.MethodPost, "/api/stt/batch", "Transcribe several audio files in one request", handleSTTBatch)
+	api.handle(http.MethodPost, "/api/stt/live", "Transcribe a recording uploaded in chunks", handleSTTLive)
+	api.handle(http.MethodGet, "/api/stt/formats", "Supported transcription output formats", handleGetSTTFormats)
+	api.handle(http.MethodGet, "/api/stt/languages", "Languages Whisper can transcribe", handleGetSTTLanguages)
+	api.handle(http.MethodPost, "/api/translate", "Translate speech in any language into English text", handleTranslate)
+
+	api.handle(http.MethodGet, "/api/models", "Installed TTS and STT models", handleGetModels)
+	api.handle(http.MethodGet, "/api/models/registry", "Models available from the speaches.ai registry", handleGetRegistryModels)
+	api.handle(http.MethodGet, "/api/models/registry/*id", "Registry details of one model", handleGetRegistryModel)
+	api.handle(http.MethodPost, "/api/models/install", "Install a model", handleInstallModel)
+	api.handle(http.MethodGet, "/api/models/install/stream", "Install a model while streaming progress as Server-Sent Events", handleInstallModelStream)
+	api.handle(http.MethodGet, "/api/models/install/jobs", "Recent install jobs", handleGetInstallJobs)
+	api.handle(http.MethodGet, "/api/models/details/*id", "Details of one installed model", handleGetModel)
+	api.handle(http.MethodDelete, "/api/models/*id", "Remove an installed model", handleDeleteModel)
+
+	api.handle(http.MethodGet, "/api/diagnostics/full", "Provisioning report for operators", handleDiagnosticsFull)
+	api.handle(http.MethodGet, "/api/support-bundle", "Redacted config and diagnostics to attach to issue reports", handleSupportBundle)
+	api.handle(http.MethodGet, "/api/stats", "Per-voice synthesis counts and voice preview cache size", handleGetStats)
+
+	// Admin endpoints require ADMIN_TOKEN
+	admin := api.group("/api/admin", requireAdmin)
+	admin.handle(http.MethodPost, "/cache/clear", "Flush the in-memory caches (admin token required)", handleClearCaches)
+	admin.handle(http.MethodPost, "/previews/warm", "Synthesize uncached voice previews in the background (admin token required)", handleWarmPreviews)
+
+	// Debug endpoints are only mounted when DEBUG=true
+	if debugEnabled() {
+		api.handle(http.MethodGet, "/api/debug/models/raw", "Raw speaches.ai /v1/models response for troubleshooting", handleDebugRawModels)
+		api.handle(http.MethodGet, "/api/debug/resolve", "How a TTS model and voice resolve to the upstream model ID", handleDebugResolve)
+		api.handle(http.MethodGet, "/api/errors/:request_id", "Stored detail of a recent failed request, by its X-Request-ID", handleGetError)
+	}
 
 	// Start the server on port 5420
 	// INFO: Server listening on http://localhost:5420
-	router.Run(":5420")
+	server := &http.Server{
+		Addr:    listenAddr,
+		Handler: router,
+	}
+	runServer(server)
 }
 
 // handleGetRegistryModels fetches available models from the registry
 func handleGetRegistryModels(c *gin.Context) {
-	speachesBaseURL := os.Getenv("SPEACHES_URL")
-	if speachesBaseURL == "" {
-		speachesBaseURL = "http://localhost:8000"
+	baseURL := speachesBaseURL()
+
+	// Serve from the cache unless the caller asks for fresh data
+	refresh := c.Query("refresh") == "true"
+
+	// Optional server-side filters, so a page can fetch just the models it shows
+	modelType := c.Query("type")
+	if modelType != "" && modelType != "tts" && modelType != "stt" {
+		jsonError(c, http.StatusBadRequest, "type must be tts or stt")
+		return
+	}
+	limit, offset, err := parseRegistryPage(c)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
 	}
 
 	// Get installed models first
-	installedSet := make(map[string]bool)
-	modelsURL := speachesBaseURL + "/v1/models"
-	if resp, err := http.Get(modelsURL); err == nil {
-		defer resp.Body.Close()
-		if resp.StatusCode == http.StatusOK {
-			var modelsData struct {
-				Data []struct {
-					ID string `json:"id"`
-				} `json:"data"`
-			}
-			if json.NewDecoder(resp.Body).Decode(&modelsData) == nil {
-				for _, model := range modelsData.Data {
-					installedSet[model.ID] = true
-				}
-			}
-		}
+	installedSet, err := modelRegistry.installedModels(c.Request.Context(), baseURL, refresh)
+	if err != nil {
+		installedSet = map[string]bool{}
 	}
 
-	// Fetch available models from the registry
-	registryModels := []gin.H{}
-	registryURL := speachesBaseURL + "/v1/registry"
-	if resp, err := http.Get(registryURL); err == nil && resp.StatusCode == http.StatusOK {
-		defer resp.Body.Close()
-		var registryData struct {
-			Data []struct {
-				ID          string `json:"id"`
-				Name        string `json:"name"`
-				Description string `json:"description"`
-				Type        string `json:"type"`
-			} `json:"data"`
-		}
-		if json.NewDecoder(resp.Body).Decode(&registryData) == nil {
-			for _, model := range registryData.Data {
-				// Determine type based on model ID if not explicitly set
-				modelType := model.Type
-				if modelType == "" {
-					if isSTTModel(model.ID) {
-						modelType = "stt"
-					} else {
-						modelType = "tts"
-					}
-				}
-
-				registryModels = append(registryModels, gin.H{
-					"id":          model.ID,
-					"name":        model.Name,
-					"description": model.Description,
-					"type":        modelType,
-				})
-			}
-		}
-	}
+	// Fetch available models from the registry; on failure the fallback list is used
+	registryModels, _ := modelRegistry.registryModels(c.Request.Context(), baseURL, refresh)
 
 	// If registry fetch failed, use fallback hardcoded list
 	if len(registryModels) == 0 {
@@ -206,37 +290,48 @@ func handleGetRegistryModels(c *gin.Context) {
 		installedList = append(installedList, modelID)
 	}
 
+	// Page after filtering, so total counts the matching models
+	matching := filterRegistryModels(registryModels, modelType, c.Query("search"))
 	c.JSON(http.StatusOK, gin.H{
-		"models":    registryModels,
+		"models":    pageRegistryModels(matching, limit, offset),
+		"total":     len(matching),
+		"limit":     limit,
+		"offset":    offset,
 		"installed": installedList,
 	})
 }
 
 // handleGetModels fetches installed models from the speaches.ai server
 func handleGetModels(c *gin.Context) {
-	speachesBaseURL := os.Getenv("SPEACHES_URL")
-	if speachesBaseURL == "" {
-		speachesBaseURL = "http://localhost:8000"
-	}
-	modelsURL := speachesBaseURL + "/v1/models"
+	baseURL := speachesBaseURL()
 
-	resp, err := http.Get(modelsURL)
+	ttsModels, sttModels, err := listInstalledModels(c.Request.Context(), baseURL)
 	if err != nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error": "speaches.ai server is not available",
-			"tts":   []interface{}{},
-			"stt":   []interface{}{},
-		})
+		jsonError(c, http.StatusServiceUnavailable, "speaches.ai server is not available")
 		return
 	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tts": ttsModels,
+		"stt": sttModels,
+	})
+}
+
+// listInstalledModels returns the installed models split into TTS and STT. It
+// only fails when the server cannot be reached; an error response or an
+// unreadable model list yields empty lists.
+func listInstalledModels(ctx context.Context, speachesBaseURL string) ([]gin.H, []gin.H, error) {
+	ttsModels := []gin.H{}
+	sttModels := []gin.H{}
+
+	resp, err := speachesGet(ctx, speachesBaseURL+"/v1/models")
+	if err != nil {
+		return nil, nil, err
+	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		c.JSON(http.StatusOK, gin.H{
-			"tts": []interface{}{},
-			"stt": []interface{}{},
-		})
-		return
+		return ttsModels, sttModels, nil
 	}
 
 	var modelsData struct {
@@ -249,37 +344,30 @@ func handleGetModels(c *gin.Context) {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&modelsData); err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"tts": []interface{}{},
-			"stt": []interface{}{},
-		})
-		return
+		return ttsModels, sttModels, nil
 	}
 
-	// Categorize models
-	ttsModels := []gin.H{}
-	sttModels := []gin.H{}
-
+	// Categorize models, preferring the type the registry declares
+	types := modelRegistry.modelTypes(ctx, speachesBaseURL)
 	for _, model := range modelsData.Data {
+		kind := types.of(model.ID)
+
 		modelInfo := gin.H{
 			"id":        model.ID,
 			"name":      formatModelName(model.ID),
 			"installed": true,
-			"type":      model.OwnedBy,
+			"type":      kind,
+			"owned_by":  model.OwnedBy,
 		}
 
-		// Categorize based on model ID patterns
-		if isSTTModel(model.ID) {
+		if kind == "stt" {
 			sttModels = append(sttModels, modelInfo)
 		} else {
 			ttsModels = append(ttsModels, modelInfo)
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"tts": ttsModels,
-		"stt": sttModels,
-	})
+	return ttsModels, sttModels, nil
 }
 
 // formatModelName formats a model ID to a readable name
@@ -314,9 +402,60 @@ func formatModelName(modelID string) string {
 	}
 }
 
-// isSTTModel determines if a model is a speech-to-text model
+// registryModelTypes maps the type values the speaches.ai registry uses to "tts" or "stt"
+var registryModelTypes = map[string]string{
+	"tts":                          "tts",
+	"text-to-speech":               "tts",
+	"stt":                          "stt",
+	"speech-to-text":               "stt",
+	"automatic-speech-recognition": "stt",
+}
+
+// ttsModelPrefixes are ID prefixes of known TTS model families. They are
+// checked before the STT heuristic, which would otherwise match a TTS model
+// with "speech" in its name.
+var ttsModelPrefixes = []string{"speaches-ai/piper-", "tts-", "kokoro"}
+
+// modelType classifies a model as "tts" or "stt". MODEL_TYPE_OVERRIDES wins,
+// then the registry type when the backend provides one, then known TTS
+// families, and only then the name heuristic.
+func modelType(modelID, registryType string) string {
+	if kind, ok := modelTypeOverrides()[modelID]; ok {
+		return kind
+	}
+
+	if kind, ok := registryModelTypes[strings.ToLower(strings.TrimSpace(registryType))]; ok {
+		return kind
+	}
+
+	if hasTTSModelPrefix(modelID) {
+		return "tts"
+	}
+
+	id := strings.ToLower(strings.TrimPrefix(modelID, "/"))
+	if strings.Contains(id, "whisper") || strings.Contains(id, "speech") || strings.Contains(id, "transcription") {
+		return "stt"
+	}
+	return "tts"
+}
+
+// hasTTSModelPrefix reports whether a model ID belongs to a known TTS family.
+// Prefixes are matched against the name without its owner too, as Kokoro is
+// published under several (e.g. hexgrad/Kokoro-82M).
+func hasTTSModelPrefix(modelID string) bool {
+	id := strings.ToLower(strings.TrimPrefix(modelID, "/"))
+	_, name, _ := strings.Cut(id, "/")
+	for _, prefix := range ttsModelPrefixes {
+		if strings.HasPrefix(id, prefix) || strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSTTModel determines if a model is a speech-to-text model from its ID alone
 func isSTTModel(modelID string) bool {
-	return strings.Contains(modelID, "whisper") || strings.Contains(modelID, "speech") || strings.Contains(modelID, "transcription")
+	return modelType(modelID, "") == "stt"
 }
 
 // handleInstallModel downloads and installs a model from the speaches.ai server
@@ -325,490 +464,591 @@ func handleInstallModel(c *gin.Context) {
 		ModelID string `json:"model_id" binding:"required"`
 	}
 
-	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "model_id is required"})
+	if err := c.ShouldBindJSON(&req); err != nil {
+		jsonError(c, http.StatusBadRequest, "model_id is required")
 		return
 	}
 
-	speachesBaseURL := os.Getenv("SPEACHES_URL")
-	if speachesBaseURL == "" {
-		speachesBaseURL = "http://localhost:8000"
+	baseURL := speachesBaseURL()
+
+	// A retry with the same Idempotency-Key gets the first request's result instead of a second install
+	key := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+	var idempotent *idempotentResult
+	if key != "" {
+		if len(key) > maxIdempotencyKeyLength {
+			jsonError(c, http.StatusBadRequest, "Idempotency-Key is too long")
+			return
+		}
+
+		result, created := installIdempotency.begin(key, req.ModelID)
+		if !created {
+			if result.modelID != req.ModelID {
+				jsonError(c, http.StatusUnprocessableEntity, "Idempotency-Key was already used to install "+result.modelID)
+				return
+			}
+			select {
+			case <-result.done:
+			case <-c.Request.Context().Done():
+				return
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.JSON(result.status, result.body)
+			return
+		}
+		idempotent = result
 	}
 
-	// URL for installing the model
-	installURL := speachesBaseURL + "/v1/models/" + req.ModelID
+	flight := startInstall(c.Request.Context(), baseURL, req.ModelID)
+	if idempotent != nil {
+		// Record the install's own result rather than this request's wait for
+		// it, so a retry with the key is not handed this client's disconnect
+		go func() {
+			<-flight.done
+			status, body := installResponse(flight.status, flight.err)
+			installIdempotency.finish(key, idempotent, status, body)
+		}()
+	}
 
-	// Make a POST request to install the model
-	resp, err := http.Post(installURL, "application/json", nil)
+	status, body := installResponse(flight.wait(c.Request.Context()))
+	c.JSON(status, body)
+}
+
+// handleGetModel returns the metadata speaches.ai has for one installed model,
+// with its type and description from the registry when it is listed there
+func handleGetModel(c *gin.Context) {
+	// The wildcard keeps the slash in IDs like speaches-ai/piper-en_US-ryan-medium
+	modelID := strings.TrimPrefix(c.Param("id"), "/")
+	if modelID == "" {
+		jsonError(c, http.StatusBadRequest, "model id is required")
+		return
+	}
+
+	baseURL := speachesBaseURL()
+
+	// Escape the ID so its slash reaches speaches.ai as part of one path segment
+	resp, err := speachesGet(c.Request.Context(), baseURL+"/v1/models/"+url.PathEscape(modelID))
 	if err != nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error": "speaches.ai server is not available",
-		})
+		jsonError(c, http.StatusServiceUnavailable, "speaches.ai server is not available")
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		jsonErrorCode(c, http.StatusNotFound, errCodeModelNotFound, "model is not installed: "+modelID)
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		jsonUpstreamError(c, http.StatusBadGateway, bodyBytes)
+		return
+	}
+
+	// Keep every field the backend provides (owned_by, created, ...)
+	var model map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&model); err != nil {
+		jsonError(c, http.StatusBadGateway, "failed to decode model details")
+		return
+	}
+	model["name"] = formatModelName(modelID)
+
+	// The registry is optional; without it the type comes from the ID
+	model["type"] = modelType(modelID, "")
+	if registry, err := modelRegistry.registryModels(c.Request.Context(), baseURL, false); err == nil {
+		for _, entry := range registry {
+			if id, _ := entry["id"].(string); id == modelID {
+				model["type"] = entry["type"]
+				model["description"] = entry["description"]
+				if name, _ := entry["name"].(string); name != "" {
+					model["name"] = name
+				}
+				break
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, model)
+}
+
+// handleDeleteModel uninstalls a model from the speaches.ai server
+func handleDeleteModel(c *gin.Context) {
+	// The wildcard keeps the slash in IDs like speaches-ai/piper-en_US-ryan-medium
+	modelID := strings.TrimPrefix(c.Param("id"), "/")
+	if modelID == "" {
+		jsonError(c, http.StatusBadRequest, "model id is required")
+		return
+	}
+
+	baseURL := speachesBaseURL()
+
+	// Escape the ID so its slash reaches speaches.ai as part of one path segment
+	deleteURL := baseURL + "/v1/models/" + url.PathEscape(modelID)
+
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodDelete, deleteURL, nil)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "failed to create request")
+		return
+	}
+
+	resp, err := speachesClient.Do(req)
+	if err != nil {
+		jsonError(c, http.StatusServiceUnavailable, "speaches.ai server is not available")
 		return
 	}
 	defer resp.Body.Close()
 
-	// Read the response body
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to read server response",
-		})
+		jsonError(c, http.StatusInternalServerError, "failed to read server response")
 		return
 	}
 
-	// Check if installation was successful
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		errorMsg := string(bodyBytes)
-		c.JSON(resp.StatusCode, gin.H{
-			"error": "Failed to install model: " + errorMsg,
-		})
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		jsonUpstreamError(c, resp.StatusCode, bodyBytes)
 		return
 	}
 
+	log.Printf("Removed model %s", modelID)
+	modelRegistry.invalidateInstalled()
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "Model installed successfully",
+		"message": "Model removed successfully",
 	})
 }
 
 // handleTTS processes text-to-speech requests by calling the speaches.ai server
 func handleTTS(c *gin.Context) {
-	var req struct {
-		Text       string  `json:"text" binding:"required"`
-		Voice      string  `json:"voice"`
-		Model      string  `json:"model"`
-		Format     string  `json:"format"`      // mp3, wav, flac, pcm
-		Speed      float64 `json:"speed"`      // 0.25–4.0
-		SampleRate int     `json:"sample_rate"` // 8000–48000 Hz
-	}
+	var req ttsRequest
 
-	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "text field is required"})
+	if err := c.ShouldBindJSON(&req); err != nil {
+		jsonError(c, http.StatusBadRequest, "text field is required")
 		return
 	}
 
+	// Whitespace-only text would only synthesize silence
+	req.Text = normalizeTTSText(req.Text)
 	if req.Text == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "text cannot be empty"})
-		return
-	}
-
-	// Validate and set default format (supported formats: mp3, wav, flac, pcm)
-	validFormats := map[string]string{
-		"mp3":  "audio/mpeg",
-		"wav":  "audio/wav",
-		"flac": "audio/flac",
-		"pcm":  "audio/pcm",
-	}
-	format := req.Format
-	if _, ok := validFormats[format]; !ok {
-		format = "mp3" // Default to MP3
-	}
-
-	// Validate and set default speed (0.25–4.0)
-	speed := req.Speed
-	if speed == 0 {
-		speed = 1.0 // Default to normal speed
-	}
-	if speed < 0.25 {
-		speed = 0.25 // Minimum speed
-	}
-	if speed > 4.0 {
-		speed = 4.0 // Maximum speed
-	}
-
-	// Validate and set default sample rate (8000–48000 Hz)
-	sampleRate := req.SampleRate
-	if sampleRate == 0 {
-		sampleRate = 24000 // Default to 24 kHz (good balance of quality and file size)
-	}
-	if sampleRate < 8000 {
-		sampleRate = 8000 // Minimum sample rate
-	}
-	if sampleRate > 48000 {
-		sampleRate = 48000 // Maximum sample rate
-	}
-
-	// Set default model if not provided
-	model := req.Model
-	if model == "" {
-		model = "tts-1"
-	}
-
-	// Set default voice if not provided
-	voice := req.Voice
-
-	// Validate voice based on model
-	kokoroVoices := map[string]bool{
-		// American Female
-		"af_nova":   true,
-		"af_sarah":  true,
-		"af_bella":  true,
-		"af_heart":  true,
-		"af_aoede":  true,
-		"af_jessica": true,
-		"af_kore":   true,
-		"af_nicole": true,
-		"af_river":  true,
-		"af_sky":    true,
-		"af_alloy":  true,
-		// American Male
-		"am_adam":    true,
-		"am_echo":    true,
-		"am_liam":    true,
-		"am_onyx":    true,
-		"am_michael": true,
-		"am_eric":    true,
-		"am_fenrir":  true,
-		"am_puck":    true,
-		"am_santa":   true,
-		// British Female
-		"bf_alice":     true,
-		"bf_emma":      true,
-		"bf_isabella":  true,
-		"bf_lily":      true,
-		// British Male
-		"bm_fable":  true,
-		"bm_george": true,
-		"bm_daniel": true,
-		"bm_lewis":  true,
-	}
-
-	piperVoices := map[string]bool{
-		// English US - Ryan
-		"en_US-ryan-high":   true,
-		"en_US-ryan-low":    true,
-		"en_US-ryan-medium": true,
-		// English US - Female
-		"en_US-amy-low":           true,
-		"en_US-amy-medium":        true,
-		"en_US-hfc_female-medium": true,
-		"en_US-kathleen-low":      true,
-		"en_US-kristin-medium":    true,
-		"en_US-ljspeech-high":     true,
-		"en_US-ljspeech-medium":   true,
-		// English US - Male
-		"en_US-hfc_male-medium": true,
-		"en_US-lessac-high":     true,
-		"en_US-lessac-low":      true,
-		"en_US-lessac-medium":   true,
-		"en_US-danny-low":       true,
-		"en_US-joe-medium":      true,
-		"en_US-john-medium":     true,
-		"en_US-bryce-medium":    true,
-		"en_US-kusal-medium":    true,
-		"en_US-norman-medium":   true,
-		// English US - Other
-		"en_US-libritts-high":     true,
-		"en_US-libritts_r-medium": true,
-		"en_US-arctic-medium":     true,
-		"en_US-l2arctic-medium":   true,
-		// English GB
-		"en_GB-alan-low":                     true,
-		"en_GB-alan-medium":                  true,
-		"en_GB-southern_english_female-low":  true,
-		"en_GB-alba-medium":                  true,
-		"en_GB-aru-medium":                   true,
-		"en_GB-cori-high":                    true,
-		"en_GB-cori-medium":                  true,
-		"en_GB-jenny_dioco-medium":           true,
-		"en_GB-northern_english_male-medium": true,
-		"en_GB-semaine-medium":               true,
-		"en_GB-vctk-medium":                  true,
-	}
-
-	// Validate and set defaults based on model
-	var actualModel string
-	if model == "tts-1" {
-		if !kokoroVoices[voice] {
-			voice = "af_nova"
-		}
-		actualModel = "tts-1"
-	} else if model == "tts-1-piper" {
-		if !piperVoices[voice] {
-			voice = "en_US-ryan-medium"
+		jsonErrorCode(c, http.StatusBadRequest, errCodeEmptyInput, "text cannot be empty")
+		return
+	}
+
+	// A per-request autodownload overrides AUTO_DOWNLOAD
+	c.Request = c.Request.WithContext(withAutoDownload(c.Request.Context(), req.AutoDownload))
+
+	if err := validateTTSFormat(req.Format); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := validateSpeed(req.Speed); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Check SSML here rather than spend GPU time on markup the backend will reject
+	if err := validateInputFormat(req.InputFormat); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.InputFormat == "ssml" {
+		if err := validateSSML(req.Text); err != nil {
+			jsonError(c, http.StatusBadRequest, err.Error())
+			return
 		}
-		// For Piper, the model is the full path: speaches-ai/piper-{voice}
-		actualModel = "speaches-ai/piper-" + voice
-	} else {
-		// Unknown model, default to Kokoro
-		model = "tts-1"
-		voice = "af_nova"
-		actualModel = "tts-1"
 	}
 
-	// Create request payload for speaches.ai server (OpenAI API compatible)
-	payload := map[string]interface{}{
-		"model":            actualModel,
-		"input":            req.Text,
-		"voice":            voice,
-		"response_format":  format,
-		"speed":            speed,
-		"sample_rate":      sampleRate,
+	// Installed models other than the two aliases are sent upstream as they are
+	passthrough := isPassthroughTTSModel(c.Request.Context(), req.Model)
+	if err := validateTTSModel(req.Model); err != nil && !passthrough {
+		jsonErrorCode(c, http.StatusBadRequest, errCodeUnknownModel, err.Error())
+		return
 	}
 
-	jsonPayload, err := json.Marshal(payload)
+	if err := checkVoiceAllowed(req.Model, req.Voice, passthrough); err != nil {
+		jsonErrorCode(c, http.StatusForbidden, errCodeVoiceNotAllowed, err.Error())
+		return
+	}
+
+	// Apply defaults and limits, and resolve the upstream model ID. Only the
+	// aliases get their voice defaulted; a passthrough model keeps the voice given.
+	opts := req.options()
+	if passthrough {
+		opts.Model, opts.Voice, opts.ActualModel = req.Model, req.Voice, req.Model
+	}
+
+	// Create request payload for speaches.ai server (OpenAI API compatible)
+	jsonPayload, err := opts.payload(req.Text)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to marshal request"})
+		jsonError(c, http.StatusInternalServerError, "failed to marshal request")
 		return
 	}
 
-	// Call the speaches.ai server using SPEACHES_URL environment variable
-	speachesBaseURL := os.Getenv("SPEACHES_URL")
-	if speachesBaseURL == "" {
-		speachesBaseURL = "http://localhost:8000"
+	// Serve a repeated request from the TTS cache (TTS_CACHE_MAX_MB)
+	cacheKey := ttsCacheKey(jsonPayload)
+	if ttsCacheEnabled() {
+		if audio, ok := ttsResults.get(cacheKey); ok {
+			c.Header("X-Cache", "HIT")
+			if req.Share && shareEnabled() && !shareAudio(c, audio, opts.Format) {
+				return
+			}
+			streamAudio(c, speechDisposition(req, opts.Format), ttsFormats[opts.Format], bytes.NewReader(audio))
+			return
+		}
+		c.Header("X-Cache", "MISS")
 	}
-	speachesURL := speachesBaseURL + "/v1/audio/speech"
 
-	// Try to make the TTS request
-	resp, err := http.Post(speachesURL, "application/json", bytes.NewBuffer(jsonPayload))
+	// Call the speaches.ai server
+	baseURL := speachesBaseURL()
+
+	if debugEnabled() {
+		log.Printf("TTS: model=%s voice=%s text=%q", opts.Model, opts.Voice, truncateForLog(req.Text))
+	}
+
+	// Count the outcome per voice for /api/stats
+	succeeded := false
+	defer func() {
+		ttsVoiceStats.record(opts.Model, opts.Voice, succeeded)
+	}()
+
+	// Bound the upstream call by the request deadline (X-Timeout-Ms or the default)
+	ctx, cancel := upstreamContext(c)
+	defer cancel()
+
+	// Try to make the TTS request, downloading a missing Piper voice if needed
+	resp, downloaded, err := postSpeech(ctx, baseURL, opts.Model, opts.Voice, jsonPayload)
 	if err != nil {
-		// ERROR: Failed to connect to speaches.ai server on localhost:8000
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "speaches.ai server is not available. Make sure it's running on localhost:8000"})
+		if errors.Is(err, errSpeechAfterDownload) {
+			jsonError(c, http.StatusServiceUnavailable, "Failed to generate speech after downloading model")
+			return
+		}
+		jsonUpstreamCallError(c, err)
 		return
 	}
 	defer resp.Body.Close()
 
-	// Check if model needs to be downloaded
-	if resp.StatusCode != http.StatusOK {
+	// Judge the response by its Content-Type so a JSON error envelope is never streamed as audio
+	if isSpeechError(resp) {
 		body, _ := io.ReadAll(resp.Body)
-		errorMsg := string(body)
-
-		// Check if error is about missing model (for Piper voices)
-		if model == "tts-1-piper" && (bytes.Contains(body, []byte("is not installed locally")) || (bytes.Contains(body, []byte("Model")) && bytes.Contains(body, []byte("not found")))) {
-			// Auto-download the Piper voice model
-			// URL-encode the model ID for the download endpoint
-			modelID := "speaches-ai%2Fpiper-" + voice
-			downloadURL := speachesBaseURL + "/v1/models/" + modelID
-			downloadResp, downloadErr := http.Post(downloadURL, "application/json", nil)
-			if downloadErr == nil {
-				downloadResp.Body.Close()
-
-				// Retry the TTS request after downloading
-				resp2, err2 := http.Post(speachesURL, "application/json", bytes.NewBuffer(jsonPayload))
-				if err2 != nil {
-					c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to generate speech after downloading model"})
-					return
-				}
-				defer resp2.Body.Close()
-
-				if resp2.StatusCode == http.StatusOK {
-					// Success! Stream the audio with proper format headers
-					contentType := validFormats[format]
-					c.Header("Content-Type", contentType)
-					c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="speech.%s"`, format))
-					io.Copy(c.Writer, resp2.Body)
-					return
-				}
-			}
+		jsonUpstreamError(c, speechErrorStatus(resp), body)
+		return
+	}
+
+	// Explain the slow first request when the voice had to be fetched
+	markModelDownloaded(c, downloaded)
+
+	// Abort the stream early if the backend stops sending audio (TTS_STALL_TIMEOUT)
+	audioBody := watchStall(resp.Body, cancel)
+
+	// Keep a copy for a shareable link when requested and enabled
+	if req.Share && shareEnabled() {
+		audio, err := io.ReadAll(audioBody)
+		if errors.Is(err, errStreamStalled) {
+			log.Printf("TTS: %v after %s without data", err, ttsStallTimeout())
+			jsonError(c, http.StatusGatewayTimeout, "speaches.ai server stopped sending audio")
+			return
+		}
+		if err != nil {
+			jsonError(c, http.StatusBadGateway, "failed to read server response")
+			return
 		}
 
-		// If we get here, return the original error
-		c.JSON(resp.StatusCode, gin.H{"error": "speaches.ai server error: " + errorMsg})
+		if !shareAudio(c, audio, opts.Format) {
+			return
+		}
+		if ttsCacheEnabled() {
+			ttsResults.put(cacheKey, audio)
+		}
+		succeeded = streamAudio(c, speechDisposition(req, opts.Format), ttsFormats[opts.Format], bytes.NewReader(audio)) == nil
 		return
 	}
 
+	// Keep a copy of the stream for the TTS cache, unless it outgrows the cache
+	if ttsCacheEnabled() {
+		copied := &cappedBuffer{limit: ttsCacheMaxBytes()}
+		audioBody = io.TeeReader(audioBody, copied)
+		defer func() {
+			if succeeded && !copied.overflow {
+				ttsResults.put(cacheKey, copied.Bytes())
+			}
+		}()
+	}
+
+	// Stream the audio response back to the client
+	succeeded = streamAudio(c, speechDisposition(req, opts.Format), ttsFormats[opts.Format], audioBody) == nil
+}
+
+// streamAudio copies an upstream audio body to the client, tracking it so shutdown can drain it.
+// It returns the copy error, if any.
+func streamAudio(c *gin.Context, disposition, contentType string, body io.Reader) error {
+	done := activeStreams.start()
+	defer done()
+
 	// Set proper audio response headers based on selected format
-	contentType := validFormats[format]
 	c.Header("Content-Type", contentType)
-	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="speech.%s"`, format))
+	c.Header("Content-Disposition", disposition)
 
-	// Stream the audio response back to the client
-	io.Copy(c.Writer, resp.Body)
+	if _, err := io.Copy(c.Writer, body); err != nil {
+		stalled := errors.Is(err, errStreamStalled)
+		if stalled {
+			log.Printf("TTS: %v after %s without data, ending stream", err, ttsStallTimeout())
+		} else {
+			log.Printf("TTS: stream ended early: %v", err)
+		}
+
+		// Nothing was sent yet, so the audio headers can still be swapped for an error
+		if !c.Writer.Written() {
+			if stalled || errors.Is(err, context.DeadlineExceeded) {
+				jsonError(c, http.StatusGatewayTimeout, "speaches.ai server stopped sending audio")
+			} else {
+				jsonError(c, http.StatusBadGateway, "failed to read server response")
+			}
+		}
+		return err
+	}
+	return nil
 }
 
 // serveHome renders the Text-to-Speech page using templates
 func serveHome(c *gin.Context) {
+	hero := heroFor("tts")
 	data := TemplateData{
-		Title:            "🍑 Speaches UI",
-		Page:             "tts",
-		HeroTitle:        "👄 Text-to-Speech",
-		HeroDescription:  "Convert text to natural-sounding speech with multiple voices and models",
-		ContentID:        "tts",
+		Title:           "🍑 Speaches UI",
+		Page:            "tts",
+		HeroTitle:       hero.Title,
+		HeroDescription: hero.Description,
+		ContentID:       "tts",
+		Version:         version,
 	}
 
 	c.Header("Content-Type", "text/html; charset=utf-8")
 
 	// Render base.html with tts.html content template included
-	if err := templates.ExecuteTemplate(c.Writer, "base.html", data); err != nil {
+	if err := templates.Load().ExecuteTemplate(c.Writer, "base.html", data); err != nil {
 		// ERROR: Failed to render TTS template
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render page"})
+		jsonError(c, http.StatusInternalServerError, "Failed to render page")
 		return
 	}
 }
 
 // serveSTT renders the Speech-to-Text page using templates
 func serveSTT(c *gin.Context) {
+	hero := heroFor("stt")
 	data := TemplateData{
-		Title:            "🍑 Speaches UI - Speech to Text",
-		Page:             "stt",
-		HeroTitle:        "👂 Speech-to-Text",
-		HeroDescription:  "Convert speech to text with advanced transcription models",
-		ContentID:        "stt",
+		Title:           "🍑 Speaches UI - Speech to Text",
+		Page:            "stt",
+		HeroTitle:       hero.Title,
+		HeroDescription: hero.Description,
+		ContentID:       "stt",
+		Version:         version,
 	}
 
 	c.Header("Content-Type", "text/html; charset=utf-8")
 
 	// Render base.html with stt.html content template included
-	if err := templates.ExecuteTemplate(c.Writer, "base.html", data); err != nil {
+	if err := templates.Load().ExecuteTemplate(c.Writer, "base.html", data); err != nil {
 		// ERROR: Failed to render STT template
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render page"})
+		jsonError(c, http.StatusInternalServerError, "Failed to render page")
 		return
 	}
 }
 
 // serveModels renders the Models page using templates
 func serveModels(c *gin.Context) {
+	hero := heroFor("models")
 	data := TemplateData{
-		Title:            "🍑 Speaches UI - Models",
-		Page:             "models",
-		HeroTitle:        "📦 Installed Models",
-		HeroDescription:  "View and manage installed models for text-to-speech and speech-to-text",
-		ContentID:        "models",
+		Title:           "🍑 Speaches UI - Models",
+		Page:            "models",
+		HeroTitle:       hero.Title,
+		HeroDescription: hero.Description,
+		ContentID:       "models",
+		Version:         version,
 	}
 
 	c.Header("Content-Type", "text/html; charset=utf-8")
 
 	// Render base.html with models.html content template included
-	if err := templates.ExecuteTemplate(c.Writer, "base.html", data); err != nil {
+	if err := templates.Load().ExecuteTemplate(c.Writer, "base.html", data); err != nil {
 		// ERROR: Failed to render models template
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render page"})
+		jsonError(c, http.StatusInternalServerError, "Failed to render page")
 		return
 	}
 }
 
 // serveAddTTSModels renders the Add TTS Models page using templates
 func serveAddTTSModels(c *gin.Context) {
+	hero := heroFor("add-tts-models")
 	data := TemplateData{
-		Title:            "🍑 Speaches UI - Add TTS Models",
-		Page:             "add-tts-models",
-		HeroTitle:        "📥 Add Text-to-Speech Models",
-		HeroDescription:  "Browse and install TTS models from the speaches.ai registry",
-		ContentID:        "add-tts-models",
+		Title:           "🍑 Speaches UI - Add TTS Models",
+		Page:            "add-tts-models",
+		HeroTitle:       hero.Title,
+		HeroDescription: hero.Description,
+		ContentID:       "add-tts-models",
+		Version:         version,
 	}
 
 	c.Header("Content-Type", "text/html; charset=utf-8")
 
 	// Render base.html with add-tts-models.html content template included
-	if err := templates.ExecuteTemplate(c.Writer, "base.html", data); err != nil {
+	if err := templates.Load().ExecuteTemplate(c.Writer, "base.html", data); err != nil {
 		// ERROR: Failed to render add-tts-models template
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render page"})
+		jsonError(c, http.StatusInternalServerError, "Failed to render page")
 		return
 	}
 }
 
 // serveAddSTTModels renders the Add STT Models page using templates
 func serveAddSTTModels(c *gin.Context) {
+	hero := heroFor("add-stt-models")
 	data := TemplateData{
-		Title:            "🍑 Speaches UI - Add STT Models",
-		Page:             "add-stt-models",
-		HeroTitle:        "📥 Add Speech-to-Text Models",
-		HeroDescription:  "Browse and install STT models from the speaches.ai registry",
-		ContentID:        "add-stt-models",
+		Title:           "🍑 Speaches UI - Add STT Models",
+		Page:            "add-stt-models",
+		HeroTitle:       hero.Title,
+		HeroDescription: hero.Description,
+		ContentID:       "add-stt-models",
+		Version:         version,
 	}
 
 	c.Header("Content-Type", "text/html; charset=utf-8")
 
 	// Render base.html with add-stt-models.html content template included
-	if err := templates.ExecuteTemplate(c.Writer, "base.html", data); err != nil {
+	if err := templates.Load().ExecuteTemplate(c.Writer, "base.html", data); err != nil {
 		// ERROR: Failed to render add-stt-models template
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render page"})
+		jsonError(c, http.StatusInternalServerError, "Failed to render page")
+		return
+	}
+}
+
+// serveHelp renders the Help page listing the API routes
+func serveHelp(c *gin.Context) {
+	hero := heroFor("help")
+	data := TemplateData{
+		Title:           "🍑 Speaches UI - Help",
+		Page:            "help",
+		HeroTitle:       hero.Title,
+		HeroDescription: hero.Description,
+		ContentID:       "help",
+		Version:         version,
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+
+	// Render base.html with help.html content template included
+	if err := templates.Load().ExecuteTemplate(c.Writer, "base.html", data); err != nil {
+		// ERROR: Failed to render help template
+		jsonError(c, http.StatusInternalServerError, "Failed to render page")
 		return
 	}
 }
 
 // handleSTT processes speech-to-text requests by calling the speaches.ai server
 func handleSTT(c *gin.Context) {
-	// Get language and model from form data
-	language := c.DefaultPostForm("language", "en")
-	model := c.DefaultPostForm("model", "standard")
+	if !parseUploadForm(c, 1) {
+		return
+	}
+
+	// Get model and options from form data
+	model := strings.TrimSpace(c.DefaultPostForm("model", "standard"))
+	segments := c.PostForm("segments") == "true"
+	responseFormat := c.DefaultPostForm("response_format", "json")
 
 	// Get the audio file from the form
 	file, err := c.FormFile("audio")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "audio file is required"})
+		jsonError(c, http.StatusBadRequest, "audio file is required")
 		return
 	}
 
-	// Validate language
-	validLanguages := map[string]bool{
-		"en": true, "es": true, "fr": true, "de": true, "it": true,
-		"pt": true, "ja": true, "ko": true, "zh": true,
-	}
-	if !validLanguages[language] {
-		language = "en"
+	// Validate the language; "auto" lets Whisper detect it
+	language, err := parseSTTLanguage(c.DefaultPostForm("language", defaultSTTLanguage))
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	// Validate model
-	validModels := map[string]bool{
-		"fast": true, "standard": true, "accurate": true,
+	// Validate the optional decoding controls
+	beamSize, err := parseDecodingParam(c, "beam_size")
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
 	}
-	if !validModels[model] {
-		model = "standard"
+	bestOf, err := parseDecodingParam(c, "best_of")
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
 	}
-
-	// Read the audio file
-	src, err := file.Open()
+	alternatives, err := parseDecodingParam(c, "alternatives")
 	if err != nil {
-		// ERROR: Failed to open uploaded audio file
-		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to open audio file"})
+		jsonError(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	defer src.Close()
 
-	audioData, err := io.ReadAll(src)
+	// Phrases to bias recognition towards, such as product names
+	hotwords, err := parseHotwords(c)
 	if err != nil {
-		// ERROR: Failed to read audio file data
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read audio file"})
+		jsonError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Create multipart request for speaches.ai
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
-	// Add audio file to multipart request (field name must be "file")
-	part, err := writer.CreateFormFile("file", file.Filename)
+	// Segment and/or word timings for subtitles
+	granularities, err := parseTimestampGranularities(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create form file"})
+		jsonError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	_, err = part.Write(audioData)
+	// A per-request autodownload overrides AUTO_DOWNLOAD
+	autoDownload, err := parseAutoDownload(c.PostForm("autodownload"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to write audio data"})
+		jsonError(c, http.StatusBadRequest, "autodownload must be true or false")
 		return
 	}
+	c.Request = c.Request.WithContext(withAutoDownload(c.Request.Context(), autoDownload))
 
-	// Add language field
-	writer.WriteField("language", language)
-
-	// Add model field - map quality to a model identifier
-	modelValue := "whisper-1" // default model
-	writer.WriteField("model", modelValue)
+	// JSON is the default; the other formats are returned as the backend produces them
+	if !isSTTFormat(responseFormat) {
+		jsonError(c, http.StatusBadRequest, "unsupported response_format: "+responseFormat+" (use "+sttFormatIDs()+")")
+		return
+	}
 
-	writer.Close()
+	// Read, check and if needed transcode the upload
+	filename, audio, ok := readSTTUpload(c, file)
+	if !ok {
+		return
+	}
+	subtitleName := subtitleFilename(file.Filename, responseFormat)
 
 	// Call the speaches.ai server
-	speachesBaseURL := os.Getenv("SPEACHES_URL")
-	if speachesBaseURL == "" {
-		speachesBaseURL = "http://localhost:8000"
-	}
-	speachesURL := speachesBaseURL + "/v1/audio/transcriptions"
+	baseURL := speachesBaseURL()
+
+	// Bound the upstream calls by the request deadline (X-Timeout-Ms or the default)
+	ctx, cancel := upstreamContext(c)
+	defer cancel()
 
-	req, err := http.NewRequest("POST", speachesURL, body)
+	// Map the quality tier or model ID to the model sent upstream
+	modelID, err := resolveSTTModel(ctx, baseURL, model)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create request"})
+		jsonErrorCode(c, http.StatusBadRequest, errCodeUnknownModel, err.Error())
 		return
 	}
 
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+	// Segment and word timings, and the detected language, need the verbose response from the backend
+	params := sttParams{Language: language, Model: modelID, BeamSize: beamSize, BestOf: bestOf, Alternatives: alternatives, Hotwords: hotwords, Granularities: granularities}
+	if segments || len(granularities) > 0 || language == "" {
+		params.ResponseFormat = "verbose_json"
+	}
+
+	// Other formats are passed through; only verbose_json carries the requested timings
+	if responseFormat != "json" {
+		params.ResponseFormat = responseFormat
+		if responseFormat != "verbose_json" {
+			params.Granularities = nil
+		}
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	// Transcribe, downloading the model first if it is not installed
+	resp, downloaded, err := postTranscription(ctx, baseURL, filename, audio, params)
 	if err != nil {
-		// ERROR: Failed to connect to speaches.ai server
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "speaches.ai server is not available. Make sure it's running on localhost:8000"})
+		jsonUpstreamCallError(c, err)
 		return
 	}
 	defer resp.Body.Close()
@@ -816,69 +1056,88 @@ func handleSTT(c *gin.Context) {
 	// Check response status
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		errorMsg := string(bodyBytes)
-
-		// Check if error is about missing model and try to download it
-		if bytes.Contains(bodyBytes, []byte("is not installed locally")) || (bytes.Contains(bodyBytes, []byte("Model")) && bytes.Contains(bodyBytes, []byte("not found"))) {
-			// Try to download the model
-			downloadURL := speachesBaseURL + "/v1/models/whisper-1"
-			downloadResp, downloadErr := http.Post(downloadURL, "application/json", nil)
-			if downloadErr == nil {
-				downloadResp.Body.Close()
-
-				// Retry the transcription request after downloading
-				// Recreate the request body since the previous one was consumed
-				body2 := &bytes.Buffer{}
-				writer2 := multipart.NewWriter(body2)
-
-				part2, _ := writer2.CreateFormFile("file", file.Filename)
-				part2.Write(audioData)
-
-				writer2.WriteField("language", language)
-				writer2.WriteField("model", "whisper-1")
-				writer2.Close()
-
-				req2, err2 := http.NewRequest("POST", speachesURL, body2)
-				if err2 == nil {
-					req2.Header.Set("Content-Type", writer2.FormDataContentType())
-
-					resp2, err3 := client.Do(req2)
-					if err3 == nil {
-						defer resp2.Body.Close()
-
-						if resp2.StatusCode == http.StatusOK {
-							// Success! Parse and return the response
-							var result struct {
-								Text string `json:"text"`
-							}
-
-							json.NewDecoder(resp2.Body).Decode(&result)
-							c.JSON(http.StatusOK, gin.H{"text": result.Text})
-							return
-						}
-					}
-				}
-			}
-		}
-
-		// If we get here, return the original error
 		// ERROR: speaches.ai server returned an error
-		c.JSON(resp.StatusCode, gin.H{"error": "speaches.ai server error: " + errorMsg})
+		jsonUpstreamError(c, resp.StatusCode, bodyBytes)
+		return
+	}
+
+	// Explain the slow first request when the model had to be fetched
+	markModelDownloaded(c, downloaded)
+
+	// Stream other formats back unchanged, subtitles as a download named after the uploaded audio
+	if responseFormat != "json" {
+		headers := map[string]string{}
+		if subtitleFormats[responseFormat] {
+			headers["Content-Disposition"] = mime.FormatMediaType("attachment", map[string]string{"filename": subtitleName})
+		}
+		c.DataFromReader(http.StatusOK, resp.ContentLength, sttContentType(responseFormat), resp.Body, headers)
 		return
 	}
 
 	// Parse the response
 	var result struct {
-		Text string `json:"text"`
+		Text         string          `json:"text"`
+		Language     string          `json:"language"`
+		Segments     json.RawMessage `json:"segments"`
+		Words        json.RawMessage `json:"words"`
+		Alternatives json.RawMessage `json:"alternatives"`
 	}
 
 	err = json.NewDecoder(resp.Body).Decode(&result)
 	if err != nil {
 		// ERROR: Failed to decode speaches.ai response
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to decode transcription response"})
+		jsonError(c, http.StatusInternalServerError, "failed to decode transcription response")
 		return
 	}
 
-	// Return the transcribed text
-	c.JSON(http.StatusOK, gin.H{"text": result.Text})
+	response := gin.H{"text": result.Text}
+
+	// The verbose response names the language that was transcribed
+	if params.ResponseFormat == "verbose_json" && result.Language != "" {
+		response["language"] = result.Language
+	}
+
+	// Requested granularities get the backend's full segment/word arrays
+	for _, granularity := range granularities {
+		timings := result.Segments
+		if granularity == "word" {
+			timings = result.Words
+		}
+		if len(timings) == 0 || string(timings) == "null" {
+			timings = json.RawMessage("[]")
+		}
+		response[granularity+"s"] = timings
+	}
+
+	// Otherwise return just the text and segment timings, leaving out the bulkier verbose fields
+	if _, full := response["segments"]; segments && !full {
+		var parsed []struct {
+			Start float64 `json:"start"`
+			End   float64 `json:"end"`
+			Text  string  `json:"text"`
+		}
+		json.Unmarshal(result.Segments, &parsed)
+
+		trimmed := make([]gin.H, len(parsed))
+		for i, segment := range parsed {
+			trimmed[i] = gin.H{"start": segment.Start, "end": segment.End, "text": segment.Text}
+		}
+		response["segments"] = trimmed
+	}
+
+	// Include the n-best hypotheses when they were asked for and the backend provided them
+	if alternatives > 1 {
+		if hypotheses := parseAlternatives(result.Alternatives, alternatives); len(hypotheses) > 1 {
+			response["alternatives"] = hypotheses
+		}
+	}
+
+	// Return the transcribed text, as plain text to clients that ask for it
+	// (e.g. curl -H "Accept: text/plain"); the web UI gets JSON
+	c.Header("Vary", "Accept")
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain) == gin.MIMEPlain {
+		c.String(http.StatusOK, result.Text)
+		return
+	}
+	c.JSON(http.StatusOK, response)
 }
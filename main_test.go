@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// TestTemplatesReloadWhileServing swaps in freshly parsed templates while pages
+// render in parallel; run with -race to catch unsynchronised access
+func TestTemplatesReloadWhileServing(t *testing.T) {
+	tmpl, err := loadTemplates()
+	if err != nil {
+		t.Fatalf("loadTemplates: %v", err)
+	}
+	templates.Store(tmpl)
+
+	pages := []gin.HandlerFunc{serveHome, serveSTT, serveModels, serveAddTTSModels, serveAddSTTModels}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			reloaded, err := loadTemplates()
+			if err != nil {
+				t.Errorf("loadTemplates: %v", err)
+				return
+			}
+			templates.Store(reloaded)
+		}
+	}()
+
+	var renders sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		renders.Add(1)
+		go func() {
+			defer renders.Done()
+			for j := 0; j < 20; j++ {
+				page := pages[(i+j)%len(pages)]
+				w := httptest.NewRecorder()
+				c, _ := gin.CreateTestContext(w)
+				c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+				page(c)
+				if w.Code != http.StatusOK || w.Body.Len() == 0 {
+					t.Errorf("render %d: status %d, %d bytes", j, w.Code, w.Body.Len())
+					return
+				}
+			}
+		}()
+	}
+	renders.Wait()
+	close(done)
+	wg.Wait()
+}
+
+func TestHandleTTSRejectsBlankText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"empty", ""},
+		{"spaces", "   "},
+		{"tabs", "\t\t"},
+		{"newlines", "\n\r\n"},
+		{"mixed whitespace", " \t\n "},
+		{"byte order mark", "\ufeff \n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]string{"text": tt.text})
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/tts", strings.NewReader(string(body)))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			handleTTS(c)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			if tt.text == "" {
+				return // rejected by the binding before the blank check
+			}
+			var got errorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decoding %q: %v", w.Body.String(), err)
+			}
+			if got.Code != errCodeEmptyInput {
+				t.Errorf("code = %q, want %q", got.Code, errCodeEmptyInput)
+			}
+		})
+	}
+}
+
+func TestModelType(t *testing.T) {
+	tests := []struct {
+		modelID      string
+		registryType string
+		want         string
+	}{
+		// The models of the fallback list on the models page
+		{"tts-1", "", "tts"},
+		{"speaches-ai/piper-en_US-ryan-high", "", "tts"},
+		{"speaches-ai/piper-en_US-ryan-medium", "", "tts"},
+		{"speaches-ai/piper-en_US-ryan-low", "", "tts"},
+		{"speaches-ai/piper-en_US-amy-medium", "", "tts"},
+		{"speaches-ai/piper-en_US-hfc_female-medium", "", "tts"},
+		{"speaches-ai/piper-en_US-lessac-high", "", "tts"},
+		{"whisper-1", "", "stt"},
+
+		// Known TTS families win over the name heuristic
+		{"speaches-ai/Kokoro-82M-v1.0-ONNX", "", "tts"},
+		{"hexgrad/Kokoro-82M", "", "tts"},
+		{"tts-1-hd", "", "tts"},
+
+		// The name heuristic is the last resort
+		{"Systran/faster-whisper-small", "", "stt"},
+		{"deepdml/faster-whisper-large-v3-turbo-ct2", "", "stt"},
+		{"acme/speech-recognizer", "", "stt"},
+		{"acme/voice", "", "tts"},
+
+		// A registry type beats both
+		{"microsoft/speecht5_tts", "text-to-speech", "tts"},
+		{"acme/whisper-voice", "tts", "tts"},
+		{"acme/narrator", "automatic-speech-recognition", "stt"},
+		{"acme/narrator", " STT ", "stt"},
+		{"acme/speech-model", "unknown", "stt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.modelID+"/"+tt.registryType, func(t *testing.T) {
+			if got := modelType(tt.modelID, tt.registryType); got != tt.want {
+				t.Errorf("modelType(%q, %q) = %q, want %q", tt.modelID, tt.registryType, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// manifestFormats are the /api/tts/long formats whose sentence timing can be
+// computed exactly from the byte count. Compressed formats have no fixed byte
+// rate, so their offsets would only be estimates.
+var manifestFormats = map[string]bool{
+	"wav": true,
+	"pcm": true,
+}
+
+// handleTTSLongManifest synthesizes text one sentence at a time and returns the
+// concatenated audio with the byte and time offsets of each sentence, so a
+// player can highlight the sentence being spoken
+func handleTTSLongManifest(c *gin.Context, opts ttsOptions, text string) {
+	if !manifestFormats[opts.Format] {
+		jsonError(c, http.StatusBadRequest, "manifest timing needs format wav or pcm; "+opts.Format+" has no exact byte offsets")
+		return
+	}
+
+	chunks := splitSentenceChunks(text, ttsChunkMaxChars())
+	if len(chunks) == 0 {
+		jsonErrorCode(c, http.StatusBadRequest, errCodeEmptyInput, "text cannot be empty")
+		return
+	}
+
+	baseURL := speachesBaseURL()
+
+	ctx, cancel := upstreamContext(c)
+	defer cancel()
+
+	// speaches.ai sends PCM as 16-bit mono; WAV declares its own rate
+	byteRate := opts.SampleRate * 2
+	var subchunks []byte
+	var samples bytes.Buffer
+	spans := make([][2]int, len(chunks))
+	downloadedModel := ""
+
+	for i, chunk := range chunks {
+		jsonPayload, err := opts.payload(chunk.Text)
+		if err != nil {
+			jsonError(c, http.StatusInternalServerError, "failed to marshal request")
+			return
+		}
+
+		resp, downloaded, err := postSpeech(ctx, baseURL, opts.Model, opts.Voice, jsonPayload)
+		if err != nil || isSpeechError(resp) {
+			status, failure := chunkFailure(resp, err)
+			writeErrorResponse(c, status, failure)
+			return
+		}
+		if downloaded != "" {
+			downloadedModel = downloaded
+		}
+
+		start := samples.Len()
+		audioBody := watchStall(resp.Body, cancel)
+		if opts.Format == "wav" {
+			var header []byte
+			if header, err = readWAVHeader(audioBody); err == nil && i == 0 {
+				subchunks = header
+				if rate := wavByteRate(header); rate > 0 {
+					byteRate = rate
+				}
+			}
+		}
+		if err == nil {
+			_, err = io.Copy(&samples, audioBody)
+		}
+		resp.Body.Close()
+		if err != nil {
+			jsonError(c, http.StatusBadGateway, "failed to read server response")
+			return
+		}
+		spans[i] = [2]int{start, samples.Len()}
+	}
+
+	// Offsets count from the start of the returned audio, header included
+	audio := samples.Bytes()
+	headerSize := 0
+	if opts.Format == "wav" {
+		header := wavHeader(subchunks, uint32(4+len(subchunks)+8+len(audio)), uint32(len(audio)))
+		headerSize = len(header)
+		audio = append(header, audio...)
+	}
+
+	millis := func(n int) int64 { return int64(n) * 1000 / int64(byteRate) }
+	sentences := make([]gin.H, len(chunks))
+	for i, chunk := range chunks {
+		sentences[i] = gin.H{
+			"index":      i,
+			"start":      chunk.Start,
+			"end":        chunk.End,
+			"text":       chunk.Text,
+			"byte_start": headerSize + spans[i][0],
+			"byte_end":   headerSize + spans[i][1],
+			"start_ms":   millis(spans[i][0]),
+			"end_ms":     millis(spans[i][1]),
+		}
+	}
+
+	markModelDownloaded(c, downloadedModel)
+	c.Header("X-TTS-Chunks", strconv.Itoa(len(chunks)))
+	c.JSON(http.StatusOK, gin.H{
+		"format":       opts.Format,
+		"content_type": ttsFormats[opts.Format],
+		"duration_ms":  millis(samples.Len()),
+		"audio":        audio,
+		"sentences":    sentences,
+	})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// parseModelTypeOverrides parses MODEL_TYPE_OVERRIDES, a comma-separated list
+// of model:type pairs such as "my-model:stt,other:tts". The type follows the
+// last colon, so model IDs may contain colons themselves.
+func parseModelTypeOverrides(raw string) (map[string]string, error) {
+	overrides := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+
+		sep := strings.LastIndex(pair, ":")
+		if sep < 0 {
+			return nil, fmt.Errorf("MODEL_TYPE_OVERRIDES entry %q must be model:tts or model:stt", pair)
+		}
+		modelID := strings.TrimSpace(pair[:sep])
+		kind := strings.ToLower(strings.TrimSpace(pair[sep+1:]))
+		if modelID == "" {
+			return nil, fmt.Errorf("MODEL_TYPE_OVERRIDES entry %q has no model id", pair)
+		}
+		if kind != "tts" && kind != "stt" {
+			return nil, fmt.Errorf("MODEL_TYPE_OVERRIDES entry %q has type %q; must be tts or stt", pair, kind)
+		}
+		overrides[modelID] = kind
+	}
+	return overrides, nil
+}
+
+// modelTypeOverrides returns the operator's tts/stt overrides by model ID. It is
+// read once; an invalid value is rejected at startup by checkModelTypeOverrides.
+var modelTypeOverrides = sync.OnceValue(func() map[string]string {
+	overrides, _ := parseModelTypeOverrides(os.Getenv("MODEL_TYPE_OVERRIDES"))
+	return overrides
+})
+
+// checkModelTypeOverrides fails when MODEL_TYPE_OVERRIDES can't be parsed, so a
+// typo is caught at startup rather than silently ignored
+func checkModelTypeOverrides() error {
+	_, err := parseModelTypeOverrides(os.Getenv("MODEL_TYPE_OVERRIDES"))
+	return err
+}
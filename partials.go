@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// modelsListPartial is the data of the models-list component
+type modelsListPartial struct {
+	Label  string
+	Models []gin.H
+}
+
+// installButtonPartial is the data of the install-button component
+type installButtonPartial struct {
+	ID        string
+	Name      string
+	Installed bool
+}
+
+// renderPartial renders one named component from templates/partials.html as
+// an HTML fragment, for pages that update part of themselves without a reload
+func renderPartial(c *gin.Context, name string, data any) {
+	// Render into a buffer so a template error can still become a 500
+	var buf bytes.Buffer
+	if err := templates.Load().ExecuteTemplate(&buf, name, data); err != nil {
+		jsonError(c, http.StatusInternalServerError, "Failed to render "+name)
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// handleVoiceOptionsPartial renders the voice dropdown options of a TTS model,
+// from the same groups as /api/voices
+func handleVoiceOptionsPartial(c *gin.Context) {
+	model := c.DefaultQuery("model", defaultTTSModel())
+	if _, ok := voiceCatalog[model]; !ok {
+		jsonError(c, http.StatusNotFound, "unknown TTS model: "+model)
+		return
+	}
+
+	renderPartial(c, "voice-options", groupVoices(availableVoices(model)))
+}
+
+// handleModelsListPartial renders the installed TTS or STT models, from the
+// same lists as /api/models
+func handleModelsListPartial(c *gin.Context) {
+	kind := c.DefaultQuery("type", "tts")
+	if kind != "tts" && kind != "stt" {
+		jsonError(c, http.StatusBadRequest, "type must be tts or stt")
+		return
+	}
+
+	baseURL := speachesBaseURL()
+
+	ttsList, sttList, err := listInstalledModels(c.Request.Context(), baseURL)
+	if err != nil {
+		jsonError(c, http.StatusServiceUnavailable, "speaches.ai server is not available")
+		return
+	}
+
+	data := modelsListPartial{Label: "TTS", Models: ttsList}
+	if kind == "stt" {
+		data = modelsListPartial{Label: "STT", Models: sttList}
+	}
+	renderPartial(c, "models-list", data)
+}
+
+// handleInstallButtonPartial renders the install button of a model, or its
+// installed badge once it is on the backend
+func handleInstallButtonPartial(c *gin.Context) {
+	modelID := c.Query("model_id")
+	if modelID == "" {
+		jsonError(c, http.StatusBadRequest, "model_id is required")
+		return
+	}
+
+	baseURL := speachesBaseURL()
+
+	installed, err := fetchInstalledModels(c.Request.Context(), baseURL)
+	if err != nil {
+		jsonError(c, http.StatusServiceUnavailable, "speaches.ai server is not available")
+		return
+	}
+
+	renderPartial(c, "install-button", installButtonPartial{
+		ID:        modelID,
+		Name:      c.DefaultQuery("name", formatModelName(modelID)),
+		Installed: installed[modelID],
+	})
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultPreviewCacheMaxMB caps the total size of cached voice previews
+	defaultPreviewCacheMaxMB = 16
+
+	// defaultPreviewCacheMaxEntries caps how many voice previews are cached
+	defaultPreviewCacheMaxEntries = 200
+
+	// defaultPreviewCacheTTL is how long a cached voice preview is served
+	defaultPreviewCacheTTL = 24 * time.Hour
+
+	// previewFormat is the audio format previews are synthesized in
+	previewFormat = "mp3"
+
+	// defaultPreviewConcurrency bounds how many previews a warm-up synthesizes at once
+	defaultPreviewConcurrency = 2
+)
+
+// previewCacheMaxBytes returns the cache size limit, overridable with
+// VOICE_PREVIEW_CACHE_MAX_MB ("0" disables the cache)
+func previewCacheMaxBytes() int {
+	if mb, err := strconv.Atoi(os.Getenv("VOICE_PREVIEW_CACHE_MAX_MB")); err == nil && mb >= 0 {
+		return mb << 20
+	}
+	return defaultPreviewCacheMaxMB << 20
+}
+
+// previewCacheMaxEntries returns the entry limit, overridable with VOICE_PREVIEW_CACHE_MAX_ENTRIES
+func previewCacheMaxEntries() int {
+	if value, err := strconv.Atoi(os.Getenv("VOICE_PREVIEW_CACHE_MAX_ENTRIES")); err == nil && value > 0 {
+		return value
+	}
+	return defaultPreviewCacheMaxEntries
+}
+
+// previewCacheTTL returns how long a preview is cached, overridable with
+// VOICE_PREVIEW_CACHE_TTL (e.g. "1h", "0" disables the cache)
+func previewCacheTTL() time.Duration {
+	value := os.Getenv("VOICE_PREVIEW_CACHE_TTL")
+	if value == "0" {
+		return 0
+	}
+	if ttl, err := time.ParseDuration(value); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultPreviewCacheTTL
+}
+
+// previewConcurrency returns how many previews a warm-up synthesizes at once,
+// overridable with PREVIEW_CONCURRENCY
+func previewConcurrency() int {
+	if value, err := strconv.Atoi(os.Getenv("PREVIEW_CONCURRENCY")); err == nil && value > 0 {
+		return value
+	}
+	return defaultPreviewConcurrency
+}
+
+// voicePreviews holds the audio served by /api/voices/preview
+var voicePreviews = newAudioLRU(func() (time.Duration, int, int) {
+	return previewCacheTTL(), previewCacheMaxBytes(), previewCacheMaxEntries()
+})
+
+func init() {
+	registerCache("previews", voicePreviews.clear)
+}
+
+// previewVoice returns the catalog entry of a voice of model, if it has one
+func previewVoice(model, voice string) (ttsVoice, bool) {
+	for _, v := range voiceCatalog[model] {
+		if v.ID == voice {
+			return v, true
+		}
+	}
+	return ttsVoice{}, false
+}
+
+// handleVoicePreview plays a voice's sample sentence. Previews are cached,
+// so repeatedly previewing the same voices doesn't hit speaches.ai each time.
+func handleVoicePreview(c *gin.Context) {
+	model := c.DefaultQuery("model", defaultTTSModel())
+	voice, ok := previewVoice(model, c.Query("voice"))
+	if !ok {
+		jsonError(c, http.StatusNotFound, "unknown voice for "+model+": "+c.Query("voice"))
+		return
+	}
+	if err := checkVoiceAllowed(model, voice.ID, false); err != nil {
+		jsonErrorCode(c, http.StatusForbidden, errCodeVoiceNotAllowed, err.Error())
+		return
+	}
+
+	key := previewKey(model, voice.ID)
+	if audio, ok := voicePreviews.get(key); ok {
+		c.Header("X-Cache", "HIT")
+		c.Data(http.StatusOK, ttsFormats[previewFormat], audio)
+		return
+	}
+
+	ctx, cancel := upstreamContext(c)
+	defer cancel()
+
+	audio, downloaded, err := synthesizePreview(ctx, speachesBaseURL(), model, voice)
+	var failure *speechFailure
+	switch {
+	case errors.As(err, &failure):
+		jsonUpstreamError(c, failure.status, failure.body)
+		return
+	case err != nil:
+		jsonUpstreamCallError(c, err)
+		return
+	}
+
+	voicePreviews.put(key, audio)
+	markModelDownloaded(c, downloaded)
+	c.Header("X-Cache", "MISS")
+	c.Data(http.StatusOK, ttsFormats[previewFormat], audio)
+}
+
+// previewKey identifies a voice's preview in voicePreviews
+func previewKey(model, voice string) string {
+	return model + "/" + voice
+}
+
+// speechFailure is an error response from speaches.ai to a speech request
+type speechFailure struct {
+	status int
+	body   []byte
+}
+
+func (e *speechFailure) Error() string {
+	return "speaches.ai server error: " + upstreamErrorMessage(e.body)
+}
+
+// synthesizePreview speaks a voice's preview sentence, returning the audio and
+// the model downloaded for it, if any. An error response from speaches.ai is
+// returned as a *speechFailure.
+func synthesizePreview(ctx context.Context, speachesBaseURL, model string, voice ttsVoice) ([]byte, string, error) {
+	_, text := voiceSampleText(voice.Locale)
+	opts := ttsRequest{Model: model, Voice: voice.ID, Format: previewFormat}.options()
+	jsonPayload, err := opts.payload(text)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, downloaded, err := postSpeech(ctx, speachesBaseURL, opts.Model, opts.Voice, jsonPayload)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	audio, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	if isSpeechError(resp) {
+		return nil, "", &speechFailure{status: speechErrorStatus(resp), body: audio}
+	}
+	return audio, downloaded, nil
+}
+
+// previewWarmupRunning is set while a warm-up runs, so only one runs at a time
+var (
+	previewWarmupMu      sync.Mutex
+	previewWarmupRunning bool
+)
+
+// previewJob is one voice preview for a warm-up to synthesize
+type previewJob struct {
+	model string
+	voice ttsVoice
+}
+
+// handleWarmPreviews starts synthesizing the previews that are not cached yet,
+// for every allowed voice or those of ?model=, in the background. At most
+// PREVIEW_CONCURRENCY previews are synthesized at once, so interactive TTS
+// requests keep most of the backend.
+func handleWarmPreviews(c *gin.Context) {
+	if previewCacheTTL() == 0 || previewCacheMaxBytes() == 0 {
+		jsonError(c, http.StatusConflict, "the voice preview cache is disabled")
+		return
+	}
+
+	model := c.Query("model")
+	if model != "" && voiceCatalog[model] == nil {
+		jsonErrorCode(c, http.StatusBadRequest, errCodeUnknownModel, "unknown model: "+model)
+		return
+	}
+
+	var jobs []previewJob
+	for _, m := range ttsModels {
+		if model != "" && m.ID != model {
+			continue
+		}
+		for _, voice := range availableVoices(m.ID) {
+			if _, ok := voicePreviews.get(previewKey(m.ID, voice.ID)); !ok {
+				jobs = append(jobs, previewJob{model: m.ID, voice: voice})
+			}
+		}
+	}
+
+	previewWarmupMu.Lock()
+	if previewWarmupRunning {
+		previewWarmupMu.Unlock()
+		jsonError(c, http.StatusConflict, "a preview warm-up is already running")
+		return
+	}
+	previewWarmupRunning = len(jobs) > 0
+	previewWarmupMu.Unlock()
+
+	concurrency := previewConcurrency()
+	if len(jobs) > 0 {
+		go func() {
+			defer func() {
+				previewWarmupMu.Lock()
+				previewWarmupRunning = false
+				previewWarmupMu.Unlock()
+			}()
+			warmPreviews(speachesBaseURL(), jobs, concurrency)
+		}()
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"queued":      len(jobs),
+		"concurrency": concurrency,
+	})
+}
+
+// warmPreviews synthesizes and caches each job's preview with a pool of
+// concurrency workers. Each synthesis gets the default request deadline.
+func warmPreviews(speachesBaseURL string, jobs []previewJob, concurrency int) {
+	started := time.Now()
+	queue := make(chan previewJob)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := 0
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				ctx, cancel := context.WithTimeout(context.Background(), defaultRequestTimeout)
+				audio, _, err := synthesizePreview(ctx, speachesBaseURL, job.model, job.voice)
+				cancel()
+				if err != nil {
+					log.Printf("preview warm-up: %s: %v", previewKey(job.model, job.voice.ID), err)
+					mu.Lock()
+					failed++
+					mu.Unlock()
+					continue
+				}
+				voicePreviews.put(previewKey(job.model, job.voice.ID), audio)
+			}
+		}()
+	}
+
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	log.Printf("preview warm-up: %d of %d previews synthesized in %s", len(jobs)-failed, len(jobs), time.Since(started).Round(time.Millisecond))
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultRegistryCacheTTL is how long the registry listing is reused when SPEACHES_REGISTRY_CACHE_TTL is unset
+	defaultRegistryCacheTTL = 60 * time.Second
+
+	// installedCacheTTL is how long the installed models are reused; they change with every install
+	installedCacheTTL = 5 * time.Second
+
+	// defaultRegistryPageSize is how many registry models are returned when no limit is given
+	defaultRegistryPageSize = 100
+
+	// maxRegistryPageSize caps the limit of one page of registry models
+	maxRegistryPageSize = 500
+)
+
+// registryCacheTTL returns the registry cache lifetime, overridable with SPEACHES_REGISTRY_CACHE_TTL (e.g. "5m", "0" disables)
+func registryCacheTTL() time.Duration {
+	value := os.Getenv("SPEACHES_REGISTRY_CACHE_TTL")
+	if value == "0" {
+		return 0
+	}
+	if ttl, err := time.ParseDuration(value); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultRegistryCacheTTL
+}
+
+// registryCache keeps the registry listing and the installed models of one
+// backend for the add-models pages, so browsing does not call speaches.ai on
+// every page load. Failed fetches are never cached. r.mu is never held during
+// a fetch; callers that miss the cache while a fetch is running wait for it.
+type registryCache struct {
+	mu               sync.Mutex
+	baseURL          string
+	models           []gin.H
+	modelsFetched    time.Time
+	modelsFetch      *registryFetch[[]gin.H]
+	installed        map[string]bool
+	installedFetched time.Time
+	installedFetch   *registryFetch[map[string]bool]
+}
+
+// registryFetch is a fetch of one of the cached lists that is still running
+type registryFetch[T any] struct {
+	done  chan struct{} // closed once value and err are set
+	value T
+	err   error
+}
+
+// modelRegistry is the cache behind /api/models/registry
+var modelRegistry = &registryCache{}
+
+func init() {
+	registerCache("registry", modelRegistry.clear)
+}
+
+// resetLocked drops entries and fetches of another backend; r.mu must be held
+func (r *registryCache) resetLocked(speachesBaseURL string) {
+	if r.baseURL != speachesBaseURL {
+		r.baseURL = speachesBaseURL
+		r.models, r.installed = nil, nil
+		r.modelsFetch, r.installedFetch = nil, nil
+	}
+}
+
+// registryModels returns the registry listing, fetching it when it is older than the TTL or refresh is set
+func (r *registryCache) registryModels(ctx context.Context, speachesBaseURL string, refresh bool) ([]gin.H, error) {
+	r.mu.Lock()
+	r.resetLocked(speachesBaseURL)
+	if !refresh && r.models != nil && time.Since(r.modelsFetched) < registryCacheTTL() {
+		models := r.models
+		r.mu.Unlock()
+		return models, nil
+	}
+
+	return awaitFetchLocked(ctx, r, &r.modelsFetch, func(ctx context.Context) ([]gin.H, error) {
+		return fetchRegistryModels(ctx, speachesBaseURL)
+	}, func(models []gin.H) {
+		r.models, r.modelsFetched = models, time.Now()
+	})
+}
+
+// installedModels returns the installed models, cached for at most installedCacheTTL
+func (r *registryCache) installedModels(ctx context.Context, speachesBaseURL string, refresh bool) (map[string]bool, error) {
+	r.mu.Lock()
+	r.resetLocked(speachesBaseURL)
+	if !refresh && r.installed != nil && time.Since(r.installedFetched) < min(installedCacheTTL, registryCacheTTL()) {
+		installed := r.installed
+		r.mu.Unlock()
+		return installed, nil
+	}
+
+	return awaitFetchLocked(ctx, r, &r.installedFetch, func(ctx context.Context) (map[string]bool, error) {
+		return fetchInstalledModels(ctx, speachesBaseURL)
+	}, func(installed map[string]bool) {
+		r.installed, r.installedFetched = installed, time.Now()
+	})
+}
+
+// awaitFetchLocked joins the fetch in *running, or starts fetch as a new one,
+// then releases r.mu and waits for it until ctx is done. The fetch runs on a
+// context detached from ctx, so a caller giving up does not fail it for the
+// others. store saves a successful result with r.mu held, unless the fetch
+// was dropped meanwhile by an invalidation or a change of backend. r.mu must
+// be held on entry.
+func awaitFetchLocked[T any](ctx context.Context, r *registryCache, running **registryFetch[T], fetch func(context.Context) (T, error), store func(T)) (T, error) {
+	current := *running
+	if current == nil {
+		current = &registryFetch[T]{done: make(chan struct{})}
+		*running = current
+
+		fetchCtx := context.WithoutCancel(ctx)
+		go func() {
+			current.value, current.err = fetch(fetchCtx)
+
+			r.mu.Lock()
+			if *running == current {
+				*running = nil
+				if current.err == nil {
+					store(current.value)
+				}
+			}
+			r.mu.Unlock()
+			close(current.done)
+		}()
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-current.done:
+		return current.value, current.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// registryTypes maps the models of the registry listing to their type, tts or stt
+type registryTypes map[string]string
+
+// modelTypes returns the types of the models in the cached registry listing.
+// The registry is optional, so when it can't be fetched the map is empty and
+// every model is left to the ID heuristics.
+func (r *registryCache) modelTypes(ctx context.Context, speachesBaseURL string) registryTypes {
+	types := registryTypes{}
+	if registry, err := r.registryModels(ctx, speachesBaseURL, false); err == nil {
+		for _, model := range registry {
+			id, _ := model["id"].(string)
+			types[id], _ = model["type"].(string)
+		}
+	}
+	return types
+}
+
+// of returns the type of a model: that of its registry entry, or the one
+// modelType derives from its ID when it isn't listed
+func (t registryTypes) of(modelID string) string {
+	if kind, ok := t[modelID]; ok {
+		return kind
+	}
+	return modelType(modelID, "")
+}
+
+// invalidateInstalled forgets the installed models after an install or
+// removal, including a fetch that may have started before it
+func (r *registryCache) invalidateInstalled() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.installed, r.installedFetch = nil, nil
+}
+
+// clear drops both cached lists and returns how many entries they held
+func (r *registryCache) clear() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	removed := len(r.models) + len(r.installed)
+	r.models, r.installed = nil, nil
+	r.modelsFetch, r.installedFetch = nil, nil
+	return removed
+}
+
+// errRegistryResponse is returned when speaches.ai answers /v1/registry with
+// an error or a body that can't be decoded
+var errRegistryResponse = errors.New("bad /v1/registry response")
+
+// fetchRegistryModels lists the models available from the speaches.ai
+// registry. Every field the backend provides is kept for the details view;
+// type is reported as tts or stt, and name and description are always set.
+func fetchRegistryModels(ctx context.Context, speachesBaseURL string) ([]gin.H, error) {
+	resp, err := speachesGet(ctx, speachesBaseURL+"/v1/registry")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", errRegistryResponse, resp.StatusCode)
+	}
+
+	var registryData struct {
+		Data []gin.H `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&registryData); err != nil {
+		return nil, fmt.Errorf("%w: %v", errRegistryResponse, err)
+	}
+
+	registryModels := make([]gin.H, 0, len(registryData.Data))
+	for _, model := range registryData.Data {
+		id, _ := model["id"].(string)
+		if id == "" {
+			continue
+		}
+		registryType, _ := model["type"].(string)
+		model["type"] = modelType(id, registryType)
+		for _, field := range []string{"name", "description"} {
+			if _, ok := model[field].(string); !ok {
+				model[field] = ""
+			}
+		}
+		registryModels = append(registryModels, model)
+	}
+	return registryModels, nil
+}
+
+// fetchInstalledModels returns the ids of the models installed on speaches.ai
+func fetchInstalledModels(ctx context.Context, speachesBaseURL string) (map[string]bool, error) {
+	resp, err := speachesGet(ctx, speachesBaseURL+"/v1/models")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("/v1/models returned %d", resp.StatusCode)
+	}
+
+	var modelsData struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&modelsData); err != nil {
+		return nil, fmt.Errorf("failed to decode /v1/models: %w", err)
+	}
+
+	installed := make(map[string]bool, len(modelsData.Data))
+	for _, model := range modelsData.Data {
+		installed[model.ID] = true
+	}
+	return installed, nil
+}
+
+// filterRegistryModels returns the models of modelType ("" for any) whose id,
+// name or description contains search, ignoring case. The cached list is left as is.
+func filterRegistryModels(models []gin.H, modelType, search string) []gin.H {
+	search = strings.ToLower(strings.TrimSpace(search))
+
+	filtered := make([]gin.H, 0, len(models))
+	for _, model := range models {
+		if modelType != "" && model["type"] != modelType {
+			continue
+		}
+		if search != "" {
+			id, _ := model["id"].(string)
+			name, _ := model["name"].(string)
+			description, _ := model["description"].(string)
+			if !strings.Contains(strings.ToLower(id+"\n"+name+"\n"+description), search) {
+				continue
+			}
+		}
+		filtered = append(filtered, model)
+	}
+	return filtered
+}
+
+// parseRegistryPage reads the limit and offset query parameters. A limit above
+// maxRegistryPageSize is capped; values that aren't integers, a limit below 1
+// or a negative offset are errors.
+func parseRegistryPage(c *gin.Context) (int, int, error) {
+	limit := defaultRegistryPageSize
+	if raw := c.Query("limit"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 {
+			return 0, 0, errors.New("limit must be a positive integer")
+		}
+		limit = min(value, maxRegistryPageSize)
+	}
+
+	offset := 0
+	if raw := c.Query("offset"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+		offset = value
+	}
+	return limit, offset, nil
+}
+
+// pageRegistryModels returns at most limit models starting at offset
+func pageRegistryModels(models []gin.H, limit, offset int) []gin.H {
+	if offset >= len(models) {
+		return []gin.H{}
+	}
+	return models[offset:min(offset+limit, len(models))]
+}
+
+// handleGetRegistryModel returns the registry metadata for a single model.
+// The id may contain slashes (speaches-ai/piper-...) either literally or URL-encoded.
+func handleGetRegistryModel(c *gin.Context) {
+	modelID := strings.TrimPrefix(c.Param("id"), "/")
+	if modelID == "" {
+		jsonError(c, http.StatusBadRequest, "model id is required")
+		return
+	}
+
+	// Look the model up in the cached listing, as /api/models/registry does
+	models, err := modelRegistry.registryModels(c.Request.Context(), speachesBaseURL(), c.Query("refresh") == "true")
+	switch {
+	case errors.Is(err, errRegistryResponse):
+		jsonError(c, http.StatusBadGateway, "failed to fetch model registry")
+		return
+	case err != nil:
+		jsonError(c, http.StatusServiceUnavailable, "speaches.ai server is not available")
+		return
+	}
+
+	for _, model := range models {
+		if model["id"] == modelID {
+			c.JSON(http.StatusOK, model)
+			return
+		}
+	}
+
+	jsonErrorCode(c, http.StatusNotFound, errCodeModelNotFound, "model not found in registry: "+modelID)
+}
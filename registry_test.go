@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRegistryCacheFetchesWithoutLock(t *testing.T) {
+	release := make(chan struct{})
+	var registryCalls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/registry":
+			registryCalls.Add(1)
+			<-release
+			w.Write([]byte(`{"data":[{"id":"tts-1"}]}`))
+		case "/v1/models":
+			w.Write([]byte(`{"data":[{"id":"whisper-1"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	cache := &registryCache{}
+
+	// A slow registry listing, with several callers waiting on it
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			models, err := cache.registryModels(context.Background(), server.URL, false)
+			if err != nil || len(models) != 1 {
+				t.Errorf("registryModels = %v, %v; want one model", models, err)
+			}
+		}()
+	}
+
+	for registryCalls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The installed models are fetched meanwhile instead of queueing behind it
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	installed, err := cache.installedModels(ctx, server.URL, false)
+	if err != nil || !installed["whisper-1"] {
+		t.Fatalf("installedModels = %v, %v while the registry was loading", installed, err)
+	}
+
+	// A caller that gives up gets its own error without failing the others
+	canceled, cancelWait := context.WithCancel(context.Background())
+	cancelWait()
+	if _, err := cache.registryModels(canceled, server.URL, false); err == nil {
+		t.Error("registryModels with a canceled context returned no error")
+	}
+
+	close(release)
+	wg.Wait()
+	if got := registryCalls.Load(); got != 1 {
+		t.Errorf("fetched the registry %d times, want 1", got)
+	}
+}
+
+func TestFetchInstalledModelsRetries(t *testing.T) {
+	t.Setenv("SPEACHES_MAX_RETRIES", "2")
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"data":[{"id":"whisper-1"}]}`))
+	}))
+	defer server.Close()
+
+	installed, err := fetchInstalledModels(context.Background(), server.URL)
+	if err != nil || !installed["whisper-1"] {
+		t.Fatalf("fetchInstalledModels = %v, %v; want whisper-1", installed, err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("sent %d requests, want 2", got)
+	}
+}
+
+func TestRegistryTypesOf(t *testing.T) {
+	types := registryTypes{"acme/narrator": "stt", "acme/whisper-voice": "tts"}
+
+	tests := []struct {
+		modelID string
+		want    string
+	}{
+		{"acme/narrator", "stt"},       // listed: the registry type wins
+		{"acme/whisper-voice", "tts"},  // listed: even against the name heuristic
+		{"whisper-1", "stt"},           // not listed: from the ID
+		{"speaches-ai/piper-x", "tts"}, // not listed: from the ID
+	}
+	for _, tt := range tests {
+		if got := types.of(tt.modelID); got != tt.want {
+			t.Errorf("of(%q) = %q, want %q", tt.modelID, got, tt.want)
+		}
+	}
+}
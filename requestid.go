@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// requestIDHeader carries the request id, from the client or generated here
+	requestIDHeader = "X-Request-ID"
+
+	// maxRequestIDLength bounds the length of a client-supplied request id
+	maxRequestIDLength = 64
+)
+
+// requestTraceKey is the context key of a request's requestTrace
+type requestTraceKey struct{}
+
+// requestTrace collects what a request did upstream, for its error record
+type requestTrace struct {
+	id             string
+	mu             sync.Mutex // upstream calls may run concurrently, as in models-compare
+	upstreamStatus int
+	upstreamPath   string
+}
+
+// upstream returns the path and status of the last speaches.ai call
+func (t *requestTrace) upstream() (string, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.upstreamPath, t.upstreamStatus
+}
+
+// newRequestID returns a random 16-character hex id
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID reports whether a client-supplied id is short and plain
+// enough to echo back and store
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// withRequestTrace returns ctx carrying trace
+func withRequestTrace(ctx context.Context, trace *requestTrace) context.Context {
+	return context.WithValue(ctx, requestTraceKey{}, trace)
+}
+
+// requestTraceFrom returns the trace of the request ctx belongs to, or nil
+func requestTraceFrom(ctx context.Context) *requestTrace {
+	trace, _ := ctx.Value(requestTraceKey{}).(*requestTrace)
+	return trace
+}
+
+// traceTransport logs each speaches.ai call with the id of the request that
+// made it, and records its status in the request's trace, so a failure can be
+// traced to the upstream response behind it
+type traceTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip sends req, then logs and notes its target, status and the time to
+// the response headers; streamed bodies may take longer to finish
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	duration := time.Since(start).Round(time.Millisecond)
+
+	trace := requestTraceFrom(req.Context())
+	requestID := "-"
+	if trace != nil {
+		requestID = trace.id
+	}
+
+	target := redactURL(req.URL.String())
+	if err != nil {
+		log.Printf("upstream: request_id=%s method=%s url=%s error=%q duration=%s", requestID, req.Method, target, err, duration)
+		return resp, err
+	}
+	log.Printf("upstream: request_id=%s method=%s url=%s status=%d duration=%s", requestID, req.Method, target, resp.StatusCode, duration)
+
+	if trace != nil {
+		trace.mu.Lock()
+		trace.upstreamStatus = resp.StatusCode
+		trace.upstreamPath = req.URL.Path
+		trace.mu.Unlock()
+	}
+	return resp, err
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTraceTransportRecordsUpstream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer server.Close()
+
+	trace := &requestTrace{id: "req-1"}
+	ctx := withRequestTrace(context.Background(), trace)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/v1/models", nil)
+
+	client := &http.Client{Transport: &traceTransport{base: http.DefaultTransport}}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	path, status := trace.upstream()
+	if path != "/v1/models" || status != http.StatusTeapot {
+		t.Errorf("trace recorded %s %d, want /v1/models %d", path, status, http.StatusTeapot)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultMaxRetries is how many times a transient upstream failure is retried
+	defaultMaxRetries = 2
+
+	// Exponential backoff between retries of a transient failure
+	retryInitialBackoff = 250 * time.Millisecond
+	retryMaxBackoff     = 2 * time.Second
+)
+
+// maxRetries returns how many retries a transient failure gets, overridable
+// with SPEACHES_MAX_RETRIES ("0" disables retries)
+func maxRetries() int {
+	if retries, err := strconv.Atoi(os.Getenv("SPEACHES_MAX_RETRIES")); err == nil && retries >= 0 {
+		return retries
+	}
+	return defaultMaxRetries
+}
+
+// transientFailure describes why an upstream call is worth retrying: a
+// connection error or a 502/503/504. It returns "" for anything else, including
+// 4xx responses, timeouts, a full request queue and the 503 of a loading
+// model, which retryWhileLoading waits out instead.
+func transientFailure(resp *http.Response, err error) string {
+	if err != nil {
+		var netErr net.Error
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, errUpstreamBusy) || (errors.As(err, &netErr) && netErr.Timeout()) {
+			return ""
+		}
+		return "is unreachable"
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return fmt.Sprintf("answered %d", resp.StatusCode)
+	case http.StatusServiceUnavailable:
+		if isModelLoading(resp) {
+			return ""
+		}
+		return fmt.Sprintf("answered %d", resp.StatusCode)
+	}
+	return ""
+}
+
+// retryTransient calls send until it stops failing transiently or the retries
+// run out, backing off exponentially between attempts. It is the one retry
+// policy for speaches.ai calls, installs included, so SPEACHES_MAX_RETRIES
+// governs them all. send must build a fresh request each time, so request
+// bodies are never reused. The last response is returned with its body intact.
+func retryTransient(ctx context.Context, send func() (*http.Response, error)) (*http.Response, error) {
+	retries := maxRetries()
+	backoff := retryInitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		resp, err := send()
+		reason := transientFailure(resp, err)
+		if reason == "" || attempt > retries {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		log.Printf("speaches.ai %s, retrying in %s (retry %d of %d)", reason, backoff, attempt, retries)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, retryMaxBackoff)
+	}
+}
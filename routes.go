@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isAPIPath reports whether a request targets the JSON API rather than an HTML page
+func isAPIPath(path string) bool {
+	return strings.HasPrefix(path, "/api/") || path == "/version" || path == "/healthz"
+}
+
+// handleNoRoute answers unknown paths with JSON for API routes and a friendly page otherwise
+func handleNoRoute(c *gin.Context) {
+	if isAPIPath(c.Request.URL.Path) {
+		jsonError(c, http.StatusNotFound, "not found")
+		return
+	}
+	renderErrorPage(c, http.StatusNotFound, "🤷 Page Not Found", "The page you were looking for doesn't exist")
+}
+
+// handleNoMethod answers known paths requested with the wrong HTTP method
+func handleNoMethod(c *gin.Context) {
+	if isAPIPath(c.Request.URL.Path) {
+		jsonError(c, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	renderErrorPage(c, http.StatusMethodNotAllowed, "🚫 Method Not Allowed", "This page can't be accessed with "+c.Request.Method)
+}
+
+// Machine-readable error codes sent in the "code" field of every JSON error
+const (
+	errCodeInvalidInput        = "invalid_input"
+	errCodeEmptyInput          = "empty_input"
+	errCodeUnknownModel        = "unknown_model"
+	errCodeVoiceNotAllowed     = "voice_not_allowed"
+	errCodeModelNotFound       = "model_not_found"
+	errCodeModelLoading        = "model_loading"
+	errCodeNotFound            = "not_found"
+	errCodeMethodNotAllowed    = "method_not_allowed"
+	errCodeUnauthorized        = "unauthorized"
+	errCodeForbidden           = "forbidden"
+	errCodeConflict            = "conflict"
+	errCodeTooLarge            = "too_large"
+	errCodeUnsupportedMedia    = "unsupported_media_type"
+	errCodeUnavailable         = "unavailable"
+	errCodeUpstreamUnavailable = "upstream_unavailable"
+	errCodeUpstreamBusy        = "upstream_busy"
+	errCodeUpstreamTimeout     = "upstream_timeout"
+	errCodeUpstreamError       = "upstream_error"
+	errCodeInternal            = "internal_error"
+)
+
+// errorResponse is the body of a JSON error: a human-readable message, kept
+// as "error" for existing clients, and a code to match on. Errors passed on
+// from speaches.ai nest its JSON error body under "upstream".
+type errorResponse struct {
+	Error    string          `json:"error"`
+	Code     string          `json:"code"`
+	Upstream json.RawMessage `json:"upstream,omitempty"`
+}
+
+// errorCodeForStatus returns the code of an error that has no more specific one
+func errorCodeForStatus(status int) string {
+	switch status {
+	case http.StatusNotFound:
+		return errCodeNotFound
+	case http.StatusMethodNotAllowed:
+		return errCodeMethodNotAllowed
+	case http.StatusUnauthorized:
+		return errCodeUnauthorized
+	case http.StatusForbidden:
+		return errCodeForbidden
+	case http.StatusConflict:
+		return errCodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return errCodeTooLarge
+	case http.StatusUnsupportedMediaType:
+		return errCodeUnsupportedMedia
+	case http.StatusBadGateway:
+		return errCodeUpstreamError
+	case http.StatusServiceUnavailable:
+		return errCodeUpstreamUnavailable
+	case http.StatusGatewayTimeout:
+		return errCodeUpstreamTimeout
+	}
+	if status >= 500 {
+		return errCodeInternal
+	}
+	return errCodeInvalidInput
+}
+
+// upstreamErrorCode returns the code of an error response from speaches.ai
+func upstreamErrorCode(body []byte) string {
+	if isModelNotInstalled(body) {
+		return errCodeModelNotFound
+	}
+	return errCodeUpstreamError
+}
+
+// upstreamError describes an error response from speaches.ai. A JSON body is
+// nested as-is under Upstream instead of being pasted into the message, which
+// then carries only the message extracted from it; any other body is quoted
+// in the message.
+func upstreamError(body []byte) errorResponse {
+	failure := errorResponse{Error: "speaches.ai server error", Code: upstreamErrorCode(body)}
+
+	body = bytes.TrimSpace(body)
+	switch {
+	case len(body) == 0:
+	case json.Valid(body):
+		failure.Upstream = json.RawMessage(body)
+		if message, ok := envelopeMessage(body); ok {
+			failure.Error += ": " + message
+		}
+	default:
+		failure.Error += ": " + string(body)
+	}
+	return failure
+}
+
+// upstreamCallError describes a call to speaches.ai that got no response: a
+// passed request deadline is a 504, a model still loading or a full queue a
+// 503 to retry shortly, and anything else a backend that could not be reached
+func upstreamCallError(err error) (int, errorResponse) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, errorResponse{Error: "speaches.ai server did not respond before the request deadline", Code: errCodeUpstreamTimeout}
+	case errors.Is(err, errModelLoading):
+		return http.StatusServiceUnavailable, errorResponse{Error: errModelLoading.Error(), Code: errCodeModelLoading}
+	case errors.Is(err, errUpstreamBusy):
+		return http.StatusServiceUnavailable, errorResponse{Error: errUpstreamBusy.Error(), Code: errCodeUpstreamBusy}
+	default:
+		return http.StatusServiceUnavailable, errorResponse{Error: "speaches.ai server is not available", Code: errCodeUpstreamUnavailable}
+	}
+}
+
+// setRetryAfter asks the client to retry shortly for errors that clear on
+// their own: a model that is loading, or every slot for speaches.ai taken
+func setRetryAfter(c *gin.Context, code string) {
+	if code == errCodeModelLoading || code == errCodeUpstreamBusy {
+		c.Header("Retry-After", "5")
+	}
+}
+
+// jsonError sends a JSON error body with the code for its status, replacing
+// any audio or HTML headers set earlier so a player or browser never receives
+// JSON labelled as something else
+func jsonError(c *gin.Context, status int, message string) {
+	jsonErrorCode(c, status, errorCodeForStatus(status), message)
+}
+
+// jsonErrorCode is jsonError with a specific machine-readable code
+func jsonErrorCode(c *gin.Context, status int, code, message string) {
+	writeErrorResponse(c, status, errorResponse{Error: message, Code: code})
+}
+
+// jsonUpstreamError sends the error response body of a failed speaches.ai call
+func jsonUpstreamError(c *gin.Context, status int, body []byte) {
+	writeErrorResponse(c, status, upstreamError(body))
+}
+
+// jsonUpstreamCallError sends the error of a speaches.ai call that got no response
+func jsonUpstreamCallError(c *gin.Context, err error) {
+	status, failure := upstreamCallError(err)
+	writeErrorResponse(c, status, failure)
+}
+
+// writeErrorResponse sends failure with the headers of jsonError, and a
+// Retry-After for errors worth retrying
+func writeErrorResponse(c *gin.Context, status int, failure errorResponse) {
+	setRetryAfter(c, failure.Code)
+	c.Header("Content-Disposition", "")
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.JSON(status, failure)
+}
+
+// renderErrorPage renders the shared layout with an error hero and the given status
+func renderErrorPage(c *gin.Context, status int, title, description string) {
+	data := TemplateData{
+		Title:           "🍑 Speaches UI - " + http.StatusText(status),
+		Page:            "error",
+		HeroTitle:       title,
+		HeroDescription: description,
+		ContentID:       "error",
+		Version:         version,
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+
+	if err := templates.Load().ExecuteTemplate(c.Writer, "base.html", data); err != nil {
+		// ERROR: Failed to render error template
+		c.String(status, http.StatusText(status))
+	}
+}
+
+// routeInfo describes one route for /api/routes and the help page
+type routeInfo struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Description string `json:"description"`
+}
+
+// apiRoutes lists the routes mounted through a routeRegistry, in registration order
+var apiRoutes []routeInfo
+
+// routeRegistry mounts routes and records them, so the route list can't drift
+// from what is actually served
+type routeRegistry struct {
+	router gin.IRouter
+	prefix string
+}
+
+// newRouteRegistry returns a registry that mounts routes on router
+func newRouteRegistry(router gin.IRouter) *routeRegistry {
+	return &routeRegistry{router: router}
+}
+
+// handle mounts handlers for method and path and records the route
+func (r *routeRegistry) handle(method, path, description string, handlers ...gin.HandlerFunc) {
+	r.router.Handle(method, path, handlers...)
+	apiRoutes = append(apiRoutes, routeInfo{Method: method, Path: r.prefix + path, Description: description})
+}
+
+// group returns a registry for routes under prefix, running handlers such as
+// requireAdmin before each of them
+func (r *routeRegistry) group(prefix string, handlers ...gin.HandlerFunc) *routeRegistry {
+	return &routeRegistry{router: r.router.Group(prefix, handlers...), prefix: r.prefix + prefix}
+}
+
+// handleGetRoutes lists the API routes with their methods and descriptions
+func handleGetRoutes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"routes": apiRoutes})
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultShareTTL is how long shared audio stays available by default
+	defaultShareTTL = time.Hour
+
+	// defaultShareMaxEntries caps how many shared clips are held in memory
+	defaultShareMaxEntries = 100
+)
+
+// sharedAudio is a synthesized clip kept server-side behind a shareable link
+type sharedAudio struct {
+	Data        []byte
+	ContentType string
+	Format      string
+	Created     time.Time
+	Expires     time.Time
+}
+
+// audioStore holds shared audio clips by id until they expire
+type audioStore struct {
+	mu      sync.Mutex
+	entries map[string]*sharedAudio
+}
+
+// sharedAudioStore holds the clips created with "share": true on /api/tts
+var sharedAudioStore = &audioStore{entries: map[string]*sharedAudio{}}
+
+// shareEnabled reports whether SHARE_AUDIO=true, which allows storing TTS results for sharing
+func shareEnabled() bool {
+	return os.Getenv("SHARE_AUDIO") == "true"
+}
+
+// shareTTL returns how long shared audio is kept, overridable with SHARE_AUDIO_TTL (e.g. "30m")
+func shareTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("SHARE_AUDIO_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultShareTTL
+}
+
+// shareMaxEntries returns the shared clip limit, overridable with SHARE_AUDIO_MAX_ENTRIES
+func shareMaxEntries() int {
+	if value, err := strconv.Atoi(os.Getenv("SHARE_AUDIO_MAX_ENTRIES")); err == nil && value > 0 {
+		return value
+	}
+	return defaultShareMaxEntries
+}
+
+// newAudioID returns a random, unguessable id for a shared clip
+func newAudioID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// put stores a clip and returns its id. When the store is full the clip
+// closest to expiry is evicted to make room.
+func (s *audioStore) put(data []byte, contentType, format string) (string, *sharedAudio, error) {
+	id, err := newAudioID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	entry := &sharedAudio{
+		Data:        data,
+		ContentType: contentType,
+		Format:      format,
+		Created:     now,
+		Expires:     now.Add(shareTTL()),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeExpiredLocked(now)
+	for len(s.entries) >= shareMaxEntries() {
+		var oldestID string
+		for candidate, e := range s.entries {
+			if oldestID == "" || e.Expires.Before(s.entries[oldestID].Expires) {
+				oldestID = candidate
+			}
+		}
+		delete(s.entries, oldestID)
+	}
+	s.entries[id] = entry
+
+	return id, entry, nil
+}
+
+// get returns a clip that has not expired yet
+func (s *audioStore) get(id string) (*sharedAudio, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok || time.Now().After(entry.Expires) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// removeExpired drops expired clips and returns how many were removed
+func (s *audioStore) removeExpired() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.removeExpiredLocked(time.Now())
+}
+
+func (s *audioStore) removeExpiredLocked(now time.Time) int {
+	removed := 0
+	for id, entry := range s.entries {
+		if now.After(entry.Expires) {
+			delete(s.entries, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// clear drops every clip and returns how many were removed
+func (s *audioStore) clear() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := len(s.entries)
+	s.entries = map[string]*sharedAudio{}
+	return removed
+}
+
+// startShareJanitor periodically removes expired shared audio
+func startShareJanitor() {
+	registerCache("shared-audio", sharedAudioStore.clear)
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			sharedAudioStore.removeExpired()
+		}
+	}()
+}
+
+// shareAudio keeps generated audio for a shareable link and points the client
+// at it with the X-Audio-* headers. It reports false after sending an error.
+func shareAudio(c *gin.Context, audio []byte, format string) bool {
+	id, entry, err := sharedAudioStore.put(audio, ttsFormats[format], format)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "failed to store shared audio")
+		return false
+	}
+
+	c.Header("X-Audio-ID", id)
+	c.Header("X-Audio-URL", "/audio/"+id)
+	c.Header("X-Audio-TTL", strconv.Itoa(int(entry.Expires.Sub(entry.Created).Seconds())))
+	c.Header("X-Audio-Expires", entry.Expires.UTC().Format(http.TimeFormat))
+	return true
+}
+
+// handleGetSharedAudio serves a shared clip by id, with range support for seeking
+func handleGetSharedAudio(c *gin.Context) {
+	entry, ok := sharedAudioStore.get(c.Param("id"))
+	if !ok {
+		jsonError(c, http.StatusNotFound, "audio not found or expired")
+		return
+	}
+
+	c.Header("Content-Type", entry.ContentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="speech.%s"`, entry.Format))
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(time.Until(entry.Expires).Seconds())))
+	http.ServeContent(c.Writer, c.Request, "", entry.Created, bytes.NewReader(entry.Data))
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// defaultShutdownGracePeriod bounds how long shutdown waits for in-flight requests
+	defaultShutdownGracePeriod = 15 * time.Second
+
+	// shutdownProgressInterval is how often draining progress is logged
+	shutdownProgressInterval = 2 * time.Second
+)
+
+// shutdownGracePeriod returns the drain timeout, overridable with SHUTDOWN_GRACE_PERIOD (e.g. "1m", "0" stops at once)
+func shutdownGracePeriod() time.Duration {
+	value := os.Getenv("SHUTDOWN_GRACE_PERIOD")
+	if value == "0" {
+		return 0
+	}
+	if period, err := time.ParseDuration(value); err == nil && period > 0 {
+		return period
+	}
+	return defaultShutdownGracePeriod
+}
+
+// activeStreams tracks streaming audio responses that are still being written
+var activeStreams = &streamTracker{}
+
+// streamTracker counts in-flight streaming responses so shutdown can drain them
+type streamTracker struct {
+	mu      sync.Mutex
+	active  int
+	waiters []chan struct{}
+}
+
+// start registers a new stream and returns the function that marks it finished
+func (t *streamTracker) start() func() {
+	t.mu.Lock()
+	t.active++
+	t.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.active--
+			if t.active == 0 {
+				for _, w := range t.waiters {
+					close(w)
+				}
+				t.waiters = nil
+			}
+		})
+	}
+}
+
+// count returns the number of streams currently in flight
+func (t *streamTracker) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.active
+}
+
+// wait blocks until every stream has finished or the context expires
+func (t *streamTracker) wait(ctx context.Context) error {
+	t.mu.Lock()
+	if t.active == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	t.waiters = append(t.waiters, ch)
+	t.mu.Unlock()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runServer serves until SIGINT/SIGTERM, then drains in-flight requests and streams
+func runServer(server *http.Server) {
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("server error: %v", err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+
+	gracePeriod := shutdownGracePeriod()
+	log.Printf("received %s, shutting down, waiting up to %s for %d active audio stream(s)", sig, gracePeriod, activeStreams.count())
+
+	ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+
+	// Stop accepting new connections while the active streams drain
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- server.Shutdown(ctx)
+	}()
+
+	// Report draining progress until the streams finish or the grace period ends
+	drained := make(chan error, 1)
+	go func() {
+		drained <- activeStreams.wait(ctx)
+	}()
+
+	ticker := time.NewTicker(shutdownProgressInterval)
+	defer ticker.Stop()
+	for waiting := true; waiting; {
+		select {
+		case err := <-drained:
+			if err != nil {
+				log.Printf("grace period expired with %d audio stream(s) still active", activeStreams.count())
+			} else {
+				log.Printf("all audio streams finished")
+			}
+			waiting = false
+		case <-ticker.C:
+			log.Printf("draining: %d audio stream(s) still active", activeStreams.count())
+		}
+	}
+
+	if err := <-shutdownErr; err != nil {
+		log.Printf("server shutdown: %v", err)
+		return
+	}
+	log.Printf("server stopped")
+}
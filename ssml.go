@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ttsInputFormats are the accepted values of a TTS request's input_format
+var ttsInputFormats = map[string]bool{"text": true, "ssml": true}
+
+// validateInputFormat rejects an input_format other than text or ssml; empty means text
+func validateInputFormat(format string) error {
+	if format != "" && !ttsInputFormats[format] {
+		return fmt.Errorf("unsupported input_format: %s (use text or ssml)", format)
+	}
+	return nil
+}
+
+// validateSSML checks that input is a single well-formed XML document, so
+// malformed markup is caught before it reaches the backend. The error names
+// the line of the problem.
+func validateSSML(input string) error {
+	decoder := xml.NewDecoder(strings.NewReader(input))
+	depth, roots := 0, 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var syntaxErr *xml.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				return fmt.Errorf("malformed SSML at line %d: %s", syntaxErr.Line, syntaxErr.Msg)
+			}
+			return fmt.Errorf("malformed SSML: %v", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				roots++
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth == 0 && len(strings.TrimSpace(string(t))) > 0 {
+				line, _ := decoder.InputPos()
+				return fmt.Errorf("malformed SSML at line %d: text outside the root element", line)
+			}
+		}
+	}
+
+	if roots != 1 {
+		return errors.New("malformed SSML: expected a single root element such as <speak>")
+	}
+	return nil
+}
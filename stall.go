@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// defaultTTSStallTimeout is how long a TTS stream may go without receiving bytes before it is aborted
+const defaultTTSStallTimeout = 30 * time.Second
+
+// errStreamStalled is returned when the backend stops sending audio mid-stream
+var errStreamStalled = errors.New("upstream audio stream stalled")
+
+// ttsStallTimeout returns the stall limit, overridable with TTS_STALL_TIMEOUT (e.g. "10s", "0" disables)
+func ttsStallTimeout() time.Duration {
+	value := os.Getenv("TTS_STALL_TIMEOUT")
+	if value == "0" {
+		return 0
+	}
+	if timeout, err := time.ParseDuration(value); err == nil && timeout > 0 {
+		return timeout
+	}
+	return defaultTTSStallTimeout
+}
+
+// stallReader cancels the upstream request when a single read waits longer than
+// the timeout. Only time spent waiting on the backend counts, so a slow client
+// does not trip it.
+type stallReader struct {
+	r       io.Reader
+	timeout time.Duration
+	timer   *time.Timer
+	stalled atomic.Bool
+}
+
+// watchStall wraps an upstream body so a stalled stream is aborted through cancel
+func watchStall(r io.Reader, cancel context.CancelFunc) io.Reader {
+	timeout := ttsStallTimeout()
+	if timeout == 0 {
+		return r
+	}
+
+	s := &stallReader{r: r, timeout: timeout}
+	s.timer = time.AfterFunc(timeout, func() {
+		s.stalled.Store(true)
+		cancel()
+	})
+	s.timer.Stop()
+	return s
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	s.timer.Reset(s.timeout)
+	n, err := s.r.Read(p)
+	s.timer.Stop()
+
+	if err != nil && s.stalled.Load() {
+		return n, errStreamStalled
+	}
+	return n, err
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// voiceStat counts the synthesis outcomes of one model/voice pair
+type voiceStat struct {
+	Model   string `json:"model"`
+	Voice   string `json:"voice"`
+	Success int    `json:"success"`
+	Failure int    `json:"failure"`
+}
+
+// voiceStatsTracker keeps per-voice synthesis counts since startup
+type voiceStatsTracker struct {
+	mu    sync.Mutex
+	stats map[string]*voiceStat
+}
+
+// ttsVoiceStats counts /api/tts outcomes per voice
+var ttsVoiceStats = &voiceStatsTracker{stats: map[string]*voiceStat{}}
+
+// record counts one synthesis for a model/voice pair
+func (t *voiceStatsTracker) record(model, voice string, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := model + "/" + voice
+	stat, exists := t.stats[key]
+	if !exists {
+		stat = &voiceStat{Model: model, Voice: voice}
+		t.stats[key] = stat
+	}
+	if ok {
+		stat.Success++
+	} else {
+		stat.Failure++
+	}
+}
+
+// snapshot returns a copy of the counts ordered by model and voice
+func (t *voiceStatsTracker) snapshot() []voiceStat {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := make([]voiceStat, 0, len(t.stats))
+	for _, stat := range t.stats {
+		stats = append(stats, *stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Model != stats[j].Model {
+			return stats[i].Model < stats[j].Model
+		}
+		return stats[i].Voice < stats[j].Voice
+	})
+	return stats
+}
+
+// handleGetStats returns per-voice synthesis success and failure counts, so
+// voices that keep failing (e.g. not installed) stand out, and the size of
+// the voice preview cache
+func handleGetStats(c *gin.Context) {
+	voices := []gin.H{}
+	for _, stat := range ttsVoiceStats.snapshot() {
+		total := stat.Success + stat.Failure
+		voices = append(voices, gin.H{
+			"model":        stat.Model,
+			"voice":        stat.Voice,
+			"success":      stat.Success,
+			"failure":      stat.Failure,
+			"failure_rate": float64(stat.Failure) / float64(total),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"voices":        voices,
+		"preview_cache": voicePreviews.stats(),
+		"tts_cache":     ttsResults.stats(),
+	})
+}
@@ -0,0 +1,689 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultSTTModel is the model transcriptions are sent to
+const defaultSTTModel = "whisper-1"
+
+// defaultMaxUploadMB is the largest audio upload accepted when SPEACHES_MAX_UPLOAD_MB is unset
+const defaultMaxUploadMB = 25
+
+// maxUploadBytes returns the upload size limit, overridable with SPEACHES_MAX_UPLOAD_MB
+func maxUploadBytes() int64 {
+	if value, err := strconv.Atoi(os.Getenv("SPEACHES_MAX_UPLOAD_MB")); err == nil && value > 0 {
+		return int64(value) << 20
+	}
+	return defaultMaxUploadMB << 20
+}
+
+// uploadFormOverhead allows for the multipart headers and the other form fields of an upload
+const uploadFormOverhead = 1 << 20
+
+// parseUploadForm caps the request body at files uploads of maxUploadBytes
+// plus the form overhead, then parses the multipart form, so an oversized
+// upload fails with 413 while it is read instead of being read in full and
+// spilled to disk first. Other parse errors are left to the handler, which
+// reports the missing file. It reports whether the handler may go on.
+func parseUploadForm(c *gin.Context, files int64) bool {
+	limit := maxUploadBytes()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, files*limit+uploadFormOverhead)
+
+	var tooLarge *http.MaxBytesError
+	if _, err := c.MultipartForm(); errors.As(err, &tooLarge) {
+		jsonError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("audio file is too large (%d MB max)", limit>>20))
+		return false
+	}
+	return true
+}
+
+// checkUploadSize rejects an upload over the size limit with 413 and reports whether it may be read
+func checkUploadSize(c *gin.Context, file *multipart.FileHeader) bool {
+	limit := maxUploadBytes()
+	if file.Size > limit {
+		jsonError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("audio file is too large (%d MB max)", limit>>20))
+		return false
+	}
+	return true
+}
+
+// sttModelTiers maps the quality levels the STT page offers to backend model IDs
+var sttModelTiers = map[string]string{
+	"fast":     "Systran/faster-whisper-small",
+	"standard": defaultSTTModel,
+	"accurate": "Systran/faster-whisper-large-v3",
+}
+
+// errUnknownSTTModel is returned for a model that is neither a tier, a known model nor installed
+var errUnknownSTTModel = errors.New("unknown STT model (use fast, standard, accurate or the ID of an installed model)")
+
+// resolveSTTModel maps a requested model to the ID sent upstream. A tier maps
+// to its model; any other ID must be a tier's model, or an installed model
+// whose registry type is speech-to-text, so a TTS model gets a 400 here
+// rather than an opaque error from the backend. When the installed models
+// cannot be listed the ID is passed through and the backend decides.
+func resolveSTTModel(ctx context.Context, speachesBaseURL, model string) (string, error) {
+	if model == "" {
+		return defaultSTTModel, nil
+	}
+	if id, ok := sttModelTiers[model]; ok {
+		return id, nil
+	}
+	for _, id := range sttModelTiers {
+		if id == model {
+			return model, nil
+		}
+	}
+
+	installed, err := fetchInstalledModels(ctx, speachesBaseURL)
+	switch {
+	case err != nil:
+		return model, nil
+	case !installed[model]:
+		return "", errUnknownSTTModel
+	case modelRegistry.modelTypes(ctx, speachesBaseURL).of(model) != "stt":
+		return "", fmt.Errorf("%s is not a speech-to-text model", model)
+	}
+	return model, nil
+}
+
+// sttLanguage is a language Whisper can transcribe
+type sttLanguage struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// sttLanguages are the languages Whisper can transcribe, by name. The codes
+// are ISO-639-1 except Whisper's own haw (Hawaiian), jw (Javanese) and yue (Cantonese).
+var sttLanguages = []sttLanguage{
+	{Code: "af", Name: "Afrikaans"},
+	{Code: "sq", Name: "Albanian"},
+	{Code: "am", Name: "Amharic"},
+	{Code: "ar", Name: "Arabic"},
+	{Code: "hy", Name: "Armenian"},
+	{Code: "as", Name: "Assamese"},
+	{Code: "az", Name: "Azerbaijani"},
+	{Code: "ba", Name: "Bashkir"},
+	{Code: "eu", Name: "Basque"},
+	{Code: "be", Name: "Belarusian"},
+	{Code: "bn", Name: "Bengali"},
+	{Code: "bs", Name: "Bosnian"},
+	{Code: "br", Name: "Breton"},
+	{Code: "bg", Name: "Bulgarian"},
+	{Code: "my", Name: "Burmese"},
+	{Code: "yue", Name: "Cantonese"},
+	{Code: "ca", Name: "Catalan"},
+	{Code: "zh", Name: "Chinese"},
+	{Code: "hr", Name: "Croatian"},
+	{Code: "cs", Name: "Czech"},
+	{Code: "da", Name: "Danish"},
+	{Code: "nl", Name: "Dutch"},
+	{Code: "en", Name: "English"},
+	{Code: "et", Name: "Estonian"},
+	{Code: "fo", Name: "Faroese"},
+	{Code: "fi", Name: "Finnish"},
+	{Code: "fr", Name: "French"},
+	{Code: "gl", Name: "Galician"},
+	{Code: "ka", Name: "Georgian"},
+	{Code: "de", Name: "German"},
+	{Code: "el", Name: "Greek"},
+	{Code: "gu", Name: "Gujarati"},
+	{Code: "ht", Name: "Haitian Creole"},
+	{Code: "ha", Name: "Hausa"},
+	{Code: "haw", Name: "Hawaiian"},
+	{Code: "he", Name: "Hebrew"},
+	{Code: "hi", Name: "Hindi"},
+	{Code: "hu", Name: "Hungarian"},
+	{Code: "is", Name: "Icelandic"},
+	{Code: "id", Name: "Indonesian"},
+	{Code: "it", Name: "Italian"},
+	{Code: "ja", Name: "Japanese"},
+	{Code: "jw", Name: "Javanese"},
+	{Code: "kn", Name: "Kannada"},
+	{Code: "kk", Name: "Kazakh"},
+	{Code: "km", Name: "Khmer"},
+	{Code: "ko", Name: "Korean"},
+	{Code: "lo", Name: "Lao"},
+	{Code: "la", Name: "Latin"},
+	{Code: "lv", Name: "Latvian"},
+	{Code: "ln", Name: "Lingala"},
+	{Code: "lt", Name: "Lithuanian"},
+	{Code: "lb", Name: "Luxembourgish"},
+	{Code: "mk", Name: "Macedonian"},
+	{Code: "mg", Name: "Malagasy"},
+	{Code: "ms", Name: "Malay"},
+	{Code: "ml", Name: "Malayalam"},
+	{Code: "mt", Name: "Maltese"},
+	{Code: "mi", Name: "Maori"},
+	{Code: "mr", Name: "Marathi"},
+	{Code: "mn", Name: "Mongolian"},
+	{Code: "ne", Name: "Nepali"},
+	{Code: "no", Name: "Norwegian"},
+	{Code: "nn", Name: "Nynorsk"},
+	{Code: "oc", Name: "Occitan"},
+	{Code: "ps", Name: "Pashto"},
+	{Code: "fa", Name: "Persian"},
+	{Code: "pl", Name: "Polish"},
+	{Code: "pt", Name: "Portuguese"},
+	{Code: "pa", Name: "Punjabi"},
+	{Code: "ro", Name: "Romanian"},
+	{Code: "ru", Name: "Russian"},
+	{Code: "sa", Name: "Sanskrit"},
+	{Code: "sr", Name: "Serbian"},
+	{Code: "sn", Name: "Shona"},
+	{Code: "sd", Name: "Sindhi"},
+	{Code: "si", Name: "Sinhala"},
+	{Code: "sk", Name: "Slovak"},
+	{Code: "sl", Name: "Slovenian"},
+	{Code: "so", Name: "Somali"},
+	{Code: "es", Name: "Spanish"},
+	{Code: "su", Name: "Sundanese"},
+	{Code: "sw", Name: "Swahili"},
+	{Code: "sv", Name: "Swedish"},
+	{Code: "tl", Name: "Tagalog"},
+	{Code: "tg", Name: "Tajik"},
+	{Code: "ta", Name: "Tamil"},
+	{Code: "tt", Name: "Tatar"},
+	{Code: "te", Name: "Telugu"},
+	{Code: "th", Name: "Thai"},
+	{Code: "bo", Name: "Tibetan"},
+	{Code: "tr", Name: "Turkish"},
+	{Code: "tk", Name: "Turkmen"},
+	{Code: "uk", Name: "Ukrainian"},
+	{Code: "ur", Name: "Urdu"},
+	{Code: "uz", Name: "Uzbek"},
+	{Code: "vi", Name: "Vietnamese"},
+	{Code: "cy", Name: "Welsh"},
+	{Code: "yi", Name: "Yiddish"},
+	{Code: "yo", Name: "Yoruba"},
+}
+
+// defaultSTTLanguage is the language transcribed when a request names none
+const defaultSTTLanguage = "en"
+
+// isSTTLanguage reports whether code is one of sttLanguages
+func isSTTLanguage(code string) bool {
+	for _, language := range sttLanguages {
+		if language.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// sttLanguageCodes lists the codes of sttLanguages, for error messages
+func sttLanguageCodes() string {
+	codes := make([]string, len(sttLanguages))
+	for i, language := range sttLanguages {
+		codes[i] = language.Code
+	}
+	sort.Strings(codes)
+	return strings.Join(codes, ", ")
+}
+
+// parseSTTLanguage normalizes the language form field. "auto" or an empty
+// value returns "", which sends no language so Whisper detects it; an
+// unsupported code is an error listing the valid ones.
+func parseSTTLanguage(value string) (string, error) {
+	language := strings.ToLower(strings.TrimSpace(value))
+	switch {
+	case language == "" || language == "auto":
+		return "", nil
+	case isSTTLanguage(language):
+		return language, nil
+	default:
+		return "", fmt.Errorf("unsupported language: %s (use auto or one of %s)", value, sttLanguageCodes())
+	}
+}
+
+// handleGetSTTLanguages lists the transcription languages
+func handleGetSTTLanguages(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"languages": sttLanguages,
+		"default":   defaultSTTLanguage,
+	})
+}
+
+// sttFormat describes a transcription output format
+type sttFormat struct {
+	ID          string `json:"id"`
+	ContentType string `json:"content_type"`
+	Description string `json:"description"`
+}
+
+// sttFormats lists the transcription output formats of the OpenAI-compatible API, default first
+var sttFormats = []sttFormat{
+	{ID: "json", ContentType: "application/json", Description: "Transcript text as JSON"},
+	{ID: "verbose_json", ContentType: "application/json", Description: "Transcript with language, duration and segment timings"},
+	{ID: "text", ContentType: "text/plain", Description: "Plain transcript text"},
+	{ID: "srt", ContentType: "text/plain", Description: "SubRip subtitles"},
+	{ID: "vtt", ContentType: "text/vtt", Description: "WebVTT subtitles"},
+}
+
+// subtitleFormats are the response formats /api/stt returns as a file download
+var subtitleFormats = map[string]bool{"srt": true, "vtt": true}
+
+// isSTTFormat reports whether format is one of sttFormats
+func isSTTFormat(format string) bool {
+	for _, f := range sttFormats {
+		if f.ID == format {
+			return true
+		}
+	}
+	return false
+}
+
+// sttFormatIDs lists the IDs of sttFormats, for error messages
+func sttFormatIDs() string {
+	ids := make([]string, len(sttFormats))
+	for i, f := range sttFormats {
+		ids[i] = f.ID
+	}
+	return strings.Join(ids, ", ")
+}
+
+// sttContentType returns the Content-Type of a transcription output format
+func sttContentType(format string) string {
+	for _, f := range sttFormats {
+		if f.ID == format {
+			return f.ContentType
+		}
+	}
+	return "application/octet-stream"
+}
+
+// subtitleFilename names a subtitle download after the uploaded audio, e.g. talk.mp3 -> talk.srt
+func subtitleFilename(upload, format string) string {
+	name := sanitizeFilename(upload, "")
+	name = strings.TrimSuffix(name, path.Ext(name))
+	if name == "" {
+		name = "transcript"
+	}
+	return name + "." + format
+}
+
+// handleGetSTTFormats lists the supported transcription output formats
+func handleGetSTTFormats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"formats": sttFormats,
+		"default": sttFormats[0].ID,
+	})
+}
+
+// maxFilenameRunes caps the length of a sanitized filename
+const maxFilenameRunes = 200
+
+// parseAlternatives reads the n-best hypotheses some backends add to a
+// transcription, either as strings or as objects with a text field
+func parseAlternatives(raw json.RawMessage, limit int) []string {
+	var items []json.RawMessage
+	if json.Unmarshal(raw, &items) != nil {
+		return nil
+	}
+
+	alternatives := []string{}
+	for _, item := range items {
+		var text string
+		if json.Unmarshal(item, &text) != nil {
+			var object struct {
+				Text string `json:"text"`
+			}
+			if json.Unmarshal(item, &object) != nil {
+				continue
+			}
+			text = object.Text
+		}
+		if text != "" && len(alternatives) < limit {
+			alternatives = append(alternatives, text)
+		}
+	}
+	return alternatives
+}
+
+// sanitizeFilename makes an uploaded filename safe to echo in headers: path
+// components, control characters (including CR/LF), quotes and backslashes are
+// stripped. It returns fallback when nothing usable remains.
+func sanitizeFilename(name, fallback string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' || r == '\\' {
+			return -1
+		}
+		return r
+	}, name)
+
+	if runes := []rune(name); len(runes) > maxFilenameRunes {
+		name = string(runes[:maxFilenameRunes])
+	}
+
+	name = strings.Trim(name, " .")
+	if name == "" || name == "/" {
+		return fallback
+	}
+	return name
+}
+
+// sttParams are the form fields sent to the speaches.ai transcription endpoint
+type sttParams struct {
+	Language       string // empty for translations, which always produce English
+	Model          string
+	ResponseFormat string // empty uses the backend default (json)
+	BeamSize       int    // 0 leaves the decoding default to the backend
+	BestOf         int    // 0 leaves the decoding default to the backend
+	Alternatives   int    // n-best hypotheses to ask for; 0 or 1 is single-best
+	Hotwords       []string
+	Granularities  []string // timestamp_granularities; needs ResponseFormat verbose_json
+}
+
+// maxDecodingCandidates is the upper limit for beam_size, best_of and alternatives
+const maxDecodingCandidates = 10
+
+// parseDecodingParam reads an optional beam_size/best_of/alternatives form value. An empty
+// value returns 0; anything outside 1–maxDecodingCandidates is an error.
+func parseDecodingParam(c *gin.Context, field string) (int, error) {
+	raw := c.PostForm(field)
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 1 || value > maxDecodingCandidates {
+		return 0, fmt.Errorf("%s must be an integer from 1 to %d", field, maxDecodingCandidates)
+	}
+	return value, nil
+}
+
+// Limits on the hotwords list, which is prepended to the decoder prompt
+const (
+	maxHotwords     = 50
+	maxHotwordRunes = 64
+)
+
+// parseHotwords reads the optional hotwords form field. It may be repeated or
+// hold comma-separated phrases; blanks are dropped.
+func parseHotwords(c *gin.Context) ([]string, error) {
+	hotwords := []string{}
+	for _, value := range c.PostFormArray("hotwords") {
+		for _, word := range strings.Split(value, ",") {
+			word = strings.TrimSpace(word)
+			if word == "" {
+				continue
+			}
+			if utf8.RuneCountInString(word) > maxHotwordRunes {
+				return nil, fmt.Errorf("hotwords must be at most %d characters each", maxHotwordRunes)
+			}
+			hotwords = append(hotwords, word)
+		}
+	}
+	if len(hotwords) > maxHotwords {
+		return nil, fmt.Errorf("at most %d hotwords are allowed", maxHotwords)
+	}
+	return hotwords, nil
+}
+
+// timestampGranularities are the timing levels a transcription can be asked for
+var timestampGranularities = map[string]bool{"segment": true, "word": true}
+
+// parseTimestampGranularities reads the optional timestamp_granularities form
+// field. It may be repeated (also as timestamp_granularities[]) or hold
+// comma-separated values.
+func parseTimestampGranularities(c *gin.Context) ([]string, error) {
+	values := append(c.PostFormArray("timestamp_granularities"), c.PostFormArray("timestamp_granularities[]")...)
+
+	granularities := []string{}
+	seen := map[string]bool{}
+	for _, value := range values {
+		for _, granularity := range strings.Split(value, ",") {
+			granularity = strings.TrimSpace(granularity)
+			if granularity == "" || seen[granularity] {
+				continue
+			}
+			if !timestampGranularities[granularity] {
+				return nil, fmt.Errorf("unsupported timestamp granularity: %s (use segment or word)", granularity)
+			}
+			seen[granularity] = true
+			granularities = append(granularities, granularity)
+		}
+	}
+	return granularities, nil
+}
+
+// audioSource opens the audio of an STT request. It is opened again for each
+// attempt, so retries stream the upload from its source instead of keeping a
+// copy in memory.
+type audioSource func() (io.ReadCloser, error)
+
+// uploadAudio streams an uploaded file, which gin keeps in memory or a temp file
+func uploadAudio(file *multipart.FileHeader) audioSource {
+	return func() (io.ReadCloser, error) {
+		return file.Open()
+	}
+}
+
+// bytesAudio serves audio that is already in memory, such as transcoder output
+func bytesAudio(data []byte) audioSource {
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+}
+
+// streamSTTForm encodes the audio and parameters as a multipart body for
+// speaches.ai, written through a pipe as the request is sent. It returns the
+// body and its Content-Type.
+func streamSTTForm(filename string, audio io.ReadCloser, params sttParams) (io.ReadCloser, string) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+
+	go func() {
+		defer audio.Close()
+		// The transport closes the reader if the request fails, which ends the copy
+		pw.CloseWithError(writeSTTForm(writer, filename, audio, params))
+	}()
+
+	return pr, writer.FormDataContentType()
+}
+
+// writeSTTForm writes the fields of a transcription request and closes the form
+func writeSTTForm(writer *multipart.Writer, filename string, audio io.Reader, params sttParams) error {
+	// Add audio file to multipart request (field name must be "file")
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, audio); err != nil {
+		return err
+	}
+
+	if params.Language != "" {
+		writer.WriteField("language", params.Language)
+	}
+	writer.WriteField("model", params.Model)
+	if params.ResponseFormat != "" {
+		writer.WriteField("response_format", params.ResponseFormat)
+	}
+	if params.BeamSize > 0 {
+		writer.WriteField("beam_size", strconv.Itoa(params.BeamSize))
+	}
+	if params.BestOf > 0 {
+		writer.WriteField("best_of", strconv.Itoa(params.BestOf))
+	}
+	if params.Alternatives > 1 {
+		writer.WriteField("alternatives", strconv.Itoa(params.Alternatives))
+	}
+	for _, granularity := range params.Granularities {
+		writer.WriteField("timestamp_granularities[]", granularity)
+	}
+	if len(params.Hotwords) > 0 {
+		// speaches.ai takes the phrases as a single string, like faster-whisper
+		writer.WriteField("hotwords", strings.Join(params.Hotwords, ", "))
+	}
+
+	return writer.Close()
+}
+
+// readSTTUpload checks an uploaded recording's sniffed type against the
+// allowlist and transcodes it when configured. Only the first bytes are read
+// here; the rest is streamed when the request is sent. It returns the filename
+// to forward and the audio; on failure the error response has been written.
+func readSTTUpload(c *gin.Context, file *multipart.FileHeader) (string, audioSource, bool) {
+	filename, audio, status, err := prepareSTTUpload(c.Request.Context(), file)
+	if err != nil {
+		jsonError(c, status, err.Error())
+		return "", nil, false
+	}
+	return filename, audio, true
+}
+
+// prepareSTTUpload does the work of readSTTUpload, returning the status and
+// error for a rejected upload instead of writing them, so a batch can report
+// each file's failure on its own
+func prepareSTTUpload(ctx context.Context, file *multipart.FileHeader) (string, audioSource, int, error) {
+	if limit := maxUploadBytes(); file.Size > limit {
+		return "", nil, http.StatusRequestEntityTooLarge, fmt.Errorf("audio file is too large (%d MB max)", limit>>20)
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		// ERROR: Failed to open uploaded audio file
+		return "", nil, http.StatusBadRequest, errors.New("failed to open audio file")
+	}
+	defer src.Close()
+
+	// http.DetectContentType never looks past the first 512 bytes
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		// ERROR: Failed to read audio file data
+		return "", nil, http.StatusInternalServerError, errors.New("failed to read audio file")
+	}
+	head = head[:n]
+
+	if status, err := checkSTTAudio(head); err != nil {
+		return "", nil, status, err
+	}
+
+	// Convert formats the backend may not accept into 16 kHz WAV when ffmpeg is available
+	// The name is forwarded in the upstream multipart headers, so strip anything that could inject into them
+	filename := sanitizeFilename(file.Filename, "audio")
+	if needsTranscode(filename) {
+		wav, err := transcodeToWAV(ctx, io.MultiReader(bytes.NewReader(head), src))
+		if err != nil {
+			return "", nil, http.StatusUnprocessableEntity, errors.New("failed to transcode audio: " + err.Error())
+		}
+		filename = strings.TrimSuffix(filename, path.Ext(filename)) + ".wav"
+		return filename, bytesAudio(wav), http.StatusOK, nil
+	}
+
+	return filename, uploadAudio(file), http.StatusOK, nil
+}
+
+// checkSTTAudio checks an upload from its first bytes, returning the status
+// and error to reject it with
+func checkSTTAudio(head []byte) (int, error) {
+	// Turn away files that are not audio at all before the backend fails on them
+	if err := checkIsAudio(head); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	// Check the upload's actual format against the allowlist, whatever its name or declared type says
+	if allowed := sttAllowedMIMETypes(); allowed != nil {
+		detected := sniffAudioType(head)
+		if detected == "" {
+			return http.StatusUnsupportedMediaType, errors.New("unrecognized audio format (allowed: " + sortedMIMETypes(allowed) + ")")
+		}
+		if !allowed[detected] {
+			return http.StatusUnsupportedMediaType, errors.New("unsupported audio type: " + detected + " (allowed: " + sortedMIMETypes(allowed) + ")")
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// postTranscription sends a transcription request to speaches.ai, waiting out a
+// model that is still loading. If the model is not installed it is downloaded
+// and the request retried once (unless autodownload is off for the request),
+// and the downloaded model ID is returned alongside the response. On failure
+// the original error response is returned with its body still readable.
+func postTranscription(ctx context.Context, speachesBaseURL, filename string, audio audioSource, params sttParams) (*http.Response, string, error) {
+	return postAudioTask(ctx, speachesBaseURL, "/v1/audio/transcriptions", filename, audio, params)
+}
+
+// postTranslation is postTranscription for /v1/audio/translations, which
+// returns English text whatever language is spoken
+func postTranslation(ctx context.Context, speachesBaseURL, filename string, audio audioSource, params sttParams) (*http.Response, string, error) {
+	return postAudioTask(ctx, speachesBaseURL, "/v1/audio/translations", filename, audio, params)
+}
+
+// postAudioTask posts audio to a Whisper endpoint, with the model loading and
+// auto-download handling described on postTranscription
+func postAudioTask(ctx context.Context, speachesBaseURL, endpoint, filename string, audio audioSource, params sttParams) (*http.Response, string, error) {
+	speachesURL := speachesBaseURL + endpoint
+
+	send := func() (*http.Response, error) {
+		return retryWhileLoading(ctx, func() (*http.Response, error) {
+			return retryTransient(ctx, func() (*http.Response, error) {
+				// Reopen the audio so every attempt sends the whole upload
+				src, err := audio()
+				if err != nil {
+					return nil, err
+				}
+				body, contentType := streamSTTForm(filename, src, params)
+				req, err := http.NewRequestWithContext(ctx, http.MethodPost, speachesURL, body)
+				if err != nil {
+					body.Close()
+					return nil, err
+				}
+				req.Header.Set("Content-Type", contentType)
+				return speachesClient.Do(req)
+			})
+		})
+	}
+
+	resp, err := send()
+	if err != nil || resp.StatusCode == http.StatusOK {
+		return resp, "", err
+	}
+
+	// Keep the error body readable for the caller
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if !isModelNotInstalled(body) || !autoDownloadAllowed(ctx) {
+		return resp, "", nil
+	}
+
+	// Try to download the model, then retry the transcription
+	downloadResp, err := postJSON(ctx, speachesBaseURL+"/v1/models/"+url.PathEscape(params.Model), nil)
+	if err != nil {
+		return resp, "", nil
+	}
+	downloadResp.Body.Close()
+
+	retryResp, err := send()
+	if err != nil || retryResp.StatusCode != http.StatusOK {
+		// Report the original error rather than the retry's
+		if err == nil {
+			retryResp.Body.Close()
+		}
+		return resp, "", nil
+	}
+	return retryResp, params.Model, nil
+}
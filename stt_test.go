@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSubtitleFilename(t *testing.T) {
+	tests := []struct {
+		name   string
+		upload string
+		format string
+		want   string
+	}{
+		{"plain name", "talk.mp3", "srt", "talk.srt"},
+		{"path traversal", "../../etc/passwd.wav", "vtt", "passwd.vtt"},
+		{"windows path", `C:\Users\me\talk.wav`, "srt", "talk.srt"},
+		{"header injection", "talk\r\nSet-Cookie: x=1.wav", "srt", "talkSet-Cookie: x=1.srt"},
+		{"quotes", `say "hi".mp3`, "srt", "say hi.srt"},
+		{"traversal only", "../", "srt", "transcript.srt"},
+		{"control characters only", "\r\n\t", "vtt", "transcript.vtt"},
+		{"empty", "", "srt", "transcript.srt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := subtitleFilename(tt.upload, tt.format)
+			if got != tt.want {
+				t.Errorf("subtitleFilename(%q, %q) = %q, want %q", tt.upload, tt.format, got, tt.want)
+			}
+			if strings.ContainsAny(got, "\r\n/\\\"") {
+				t.Errorf("subtitleFilename(%q, %q) = %q still contains an unsafe character", tt.upload, tt.format, got)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilenameLength(t *testing.T) {
+	got := sanitizeFilename(strings.Repeat("é", maxFilenameRunes+50), "fallback")
+	if n := len([]rune(got)); n != maxFilenameRunes {
+		t.Errorf("sanitizeFilename kept %d runes, want %d", n, maxFilenameRunes)
+	}
+}
+
+func TestResolveSTTModel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models":
+			w.Write([]byte(`{"data":[{"id":"whisper-1"},{"id":"tts-1"},{"id":"acme/narrator"}]}`))
+		case "/v1/registry":
+			w.Write([]byte(`{"data":[{"id":"acme/narrator","type":"automatic-speech-recognition"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		model   string
+		want    string
+		wantErr bool
+	}{
+		{"", defaultSTTModel, false},
+		{"fast", "Systran/faster-whisper-small", false},
+		{"whisper-1", "whisper-1", false},
+		{"acme/narrator", "acme/narrator", false}, // STT by its registry type
+		{"tts-1", "", true},                       // installed, but a TTS model
+		{"acme/missing", "", true},                // not installed
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			got, err := resolveSTTModel(context.Background(), server.URL, tt.model)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("resolveSTTModel(%q) = %q, %v; want %q, error %v", tt.model, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseUploadForm(t *testing.T) {
+	t.Setenv("SPEACHES_MAX_UPLOAD_MB", "1")
+
+	tests := []struct {
+		name       string
+		size       int
+		wantOK     bool
+		wantStatus int
+	}{
+		{"within the limit", 512 << 10, true, http.StatusOK},
+		{"over the limit", 3 << 20, false, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			writer := multipart.NewWriter(&body)
+			part, _ := writer.CreateFormFile("audio", "a.wav")
+			part.Write(make([]byte, tt.size))
+			writer.Close()
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/stt", &body)
+			c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+
+			if ok := parseUploadForm(c, 1); ok != tt.wantOK {
+				t.Fatalf("parseUploadForm = %v, want %v", ok, tt.wantOK)
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
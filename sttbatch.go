@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// sttBatchConcurrency bounds how many files of a batch are transcribed at once
+	sttBatchConcurrency = 3
+
+	// maxSTTBatchFiles bounds how many files one batch may contain
+	maxSTTBatchFiles = 20
+)
+
+// handleSTTBatch transcribes several uploads sent under the audio form key,
+// a few at a time. Results keep the upload order, and a file that fails only
+// carries its own error rather than failing the batch.
+func handleSTTBatch(c *gin.Context) {
+	if !parseUploadForm(c, maxSTTBatchFiles) {
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["audio"]) == 0 {
+		jsonError(c, http.StatusBadRequest, "at least one audio file is required")
+		return
+	}
+	files := form.File["audio"]
+	if len(files) > maxSTTBatchFiles {
+		jsonError(c, http.StatusBadRequest, fmt.Sprintf("too many audio files (at most %d per batch)", maxSTTBatchFiles))
+		return
+	}
+
+	language, err := parseSTTLanguage(c.DefaultPostForm("language", defaultSTTLanguage))
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	model := strings.TrimSpace(c.DefaultPostForm("model", "standard"))
+
+	baseURL := speachesBaseURL()
+
+	ctx, cancel := upstreamContext(c)
+	defer cancel()
+
+	modelID, err := resolveSTTModel(ctx, baseURL, model)
+	if err != nil {
+		jsonErrorCode(c, http.StatusBadRequest, errCodeUnknownModel, err.Error())
+		return
+	}
+	params := sttParams{Language: language, Model: modelID}
+
+	// Transcribe with bounded concurrency, keeping the upload order
+	results := make([]gin.H, len(files))
+	downloads := make([]string, len(files))
+	sem := make(chan struct{}, sttBatchConcurrency)
+	var wg sync.WaitGroup
+	for i := range files {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i], downloads[i] = transcribeBatchFile(ctx, baseURL, files[i], params)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, downloaded := range downloads {
+		if downloaded != "" {
+			markModelDownloaded(c, downloaded)
+			break
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// transcribeBatchFile transcribes one file of a batch and returns its result
+// entry and the model downloaded for it, if any
+func transcribeBatchFile(ctx context.Context, speachesBaseURL string, file *multipart.FileHeader, params sttParams) (gin.H, string) {
+	entry := gin.H{"filename": file.Filename}
+
+	filename, audio, _, err := prepareSTTUpload(ctx, file)
+	if err != nil {
+		entry["error"] = err.Error()
+		return entry, ""
+	}
+
+	resp, downloaded, err := postTranscription(ctx, speachesBaseURL, filename, audio, params)
+	if err != nil {
+		_, failure := upstreamCallError(err)
+		entry["error"] = failure.Error
+		return entry, ""
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		failure := upstreamError(body)
+		entry["error"] = failure.Error
+		if failure.Upstream != nil {
+			entry["upstream"] = failure.Upstream
+		}
+		return entry, downloaded
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		entry["error"] = "failed to decode transcription response"
+		return entry, downloaded
+	}
+
+	entry["text"] = result.Text
+	return entry, downloaded
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultRequestTimeout is the upstream deadline for TTS/STT calls when the client sets none
+	defaultRequestTimeout = 2 * time.Minute
+
+	// maxRequestTimeout is the longest upstream deadline a client may ask for
+	maxRequestTimeout = 10 * time.Minute
+)
+
+// requestTimeout returns the upstream deadline for a request. Clients may set
+// their own with an X-Timeout-Ms header, bounded by maxRequestTimeout; missing
+// or invalid values fall back to defaultRequestTimeout.
+func requestTimeout(c *gin.Context) time.Duration {
+	ms, err := strconv.ParseInt(c.GetHeader("X-Timeout-Ms"), 10, 64)
+	if err != nil || ms <= 0 {
+		return defaultRequestTimeout
+	}
+	if ms > maxRequestTimeout.Milliseconds() {
+		return maxRequestTimeout
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// upstreamContext derives the context for upstream calls made on behalf of a request.
+// It is canceled when the client disconnects or the request deadline passes.
+func upstreamContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(c.Request.Context(), requestTimeout(c))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{"missing", "", defaultRequestTimeout},
+		{"client value", "1500", 1500 * time.Millisecond},
+		{"capped", "3600000", maxRequestTimeout},
+		{"zero", "0", defaultRequestTimeout},
+		{"negative", "-5", defaultRequestTimeout},
+		{"not a number", "soon", defaultRequestTimeout},
+		{"fractional", "1.5", defaultRequestTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/tts", nil)
+			if tt.header != "" {
+				c.Request.Header.Set("X-Timeout-Ms", tt.header)
+			}
+
+			if got := requestTimeout(c); got != tt.want {
+				t.Errorf("requestTimeout() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpstreamContextDeadline(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/tts", nil)
+	c.Request.Header.Set("X-Timeout-Ms", "20")
+
+	ctx, cancel := upstreamContext(c)
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) > 20*time.Millisecond {
+		t.Fatalf("deadline = %v (set %v), want at most 20ms away", deadline, ok)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context was not canceled at its deadline")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ffmpegPath is the resolved ffmpeg binary; empty when STT transcoding is unavailable
+var ffmpegPath string
+
+// sttPassthroughExtensions are upload formats forwarded to the backend without transcoding
+var sttPassthroughExtensions = map[string]bool{
+	".wav":  true,
+	".mp3":  true,
+	".flac": true,
+}
+
+// detectFFmpeg resolves the ffmpeg binary when STT_TRANSCODE=true.
+// FFMPEG_PATH overrides the binary looked up on PATH.
+func detectFFmpeg() {
+	if os.Getenv("STT_TRANSCODE") != "true" {
+		return
+	}
+
+	path := os.Getenv("FFMPEG_PATH")
+	if path == "" {
+		path = "ffmpeg"
+	}
+
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		log.Printf("STT_TRANSCODE is enabled but ffmpeg was not found at %q; uploads will be forwarded as-is", path)
+		return
+	}
+
+	ffmpegPath = resolved
+	log.Printf("STT transcoding enabled using %s", ffmpegPath)
+}
+
+// needsTranscode reports whether an upload should be converted before it is forwarded
+func needsTranscode(filename string) bool {
+	return ffmpegPath != "" && !sttPassthroughExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// transcodeToWAV converts audio to 16 kHz mono WAV with ffmpeg.
+// The input is written to a temporary file because containers such as m4a
+// cannot be demuxed from a pipe.
+func transcodeToWAV(ctx context.Context, audio io.Reader) ([]byte, error) {
+	tmp, err := os.CreateTemp("", "speaches-ui-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, audio); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpegPath,
+		"-hide_banner", "-loglevel", "error",
+		"-i", tmp.Name(),
+		"-ar", "16000", "-ac", "1",
+		"-f", "wav", "pipe:1",
+	)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		// Include the start of ffmpeg's output so the user can see why it failed
+		snippet := strings.TrimSpace(stderr.String())
+		if len(snippet) > 300 {
+			snippet = snippet[:300] + "..."
+		}
+		return nil, fmt.Errorf("%v: %s", err, snippet)
+	}
+
+	return stdout.Bytes(), nil
+}
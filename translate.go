@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleTranslate translates speech in any language into English text by
+// calling the speaches.ai translation endpoint
+func handleTranslate(c *gin.Context) {
+	if !parseUploadForm(c, 1) {
+		return
+	}
+
+	// Get the audio file from the form
+	file, err := c.FormFile("audio")
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "audio file is required")
+		return
+	}
+
+	// Read, check and if needed transcode the upload, as for /api/stt
+	filename, audio, ok := readSTTUpload(c, file)
+	if !ok {
+		return
+	}
+
+	baseURL := speachesBaseURL()
+
+	ctx, cancel := upstreamContext(c)
+	defer cancel()
+
+	// Translate, downloading the model first if it is not installed
+	resp, downloaded, err := postTranslation(ctx, baseURL, filename, audio, sttParams{Model: defaultSTTModel})
+	if err != nil {
+		jsonUpstreamCallError(c, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		jsonUpstreamError(c, resp.StatusCode, bodyBytes)
+		return
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		jsonError(c, http.StatusInternalServerError, "failed to decode translation response")
+		return
+	}
+
+	markModelDownloaded(c, downloaded)
+	c.JSON(http.StatusOK, gin.H{"text": result.Text})
+}
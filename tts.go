@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ttsFormats maps each supported TTS output format to its Content-Type
+var ttsFormats = map[string]string{
+	"mp3":  "audio/mpeg",
+	"opus": "audio/ogg",
+	"ogg":  "audio/ogg",
+	"webm": "audio/webm",
+	"aac":  "audio/aac",
+	"wav":  "audio/wav",
+	"flac": "audio/flac",
+	"pcm":  "audio/pcm",
+}
+
+// ttsNewlines converts Windows (CRLF) and old Mac (CR) line endings to LF
+var ttsNewlines = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// normalizeTTSText strips a leading UTF-8 byte order mark, normalizes line
+// endings and trims surrounding whitespace, since text pasted from Windows
+// files otherwise causes odd pauses or errors on some backends
+func normalizeTTSText(text string) string {
+	return strings.TrimSpace(ttsNewlines.Replace(strings.TrimPrefix(text, "\ufeff")))
+}
+
+// speechFilenameWords is how many words of the input text name a downloaded file
+const speechFilenameWords = 6
+
+// ssmlMarkup matches the tags of an SSML document, which don't belong in a filename
+var ssmlMarkup = regexp.MustCompile(`<[^>]*>`)
+
+// speechFilename names generated audio after the first few words of its text,
+// e.g. "hello-world-how-are-you.mp3". Words keep only letters and digits, so
+// no path separators or control characters survive; text without any usable
+// word is named "speech".
+func speechFilename(text, inputFormat, format string) string {
+	if inputFormat == "ssml" {
+		text = ssmlMarkup.ReplaceAllString(text, " ")
+	}
+
+	words := []string{}
+	for _, field := range strings.Fields(text) {
+		word := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, field)
+		if word == "" {
+			continue
+		}
+		if words = append(words, word); len(words) == speechFilenameWords {
+			break
+		}
+	}
+
+	name := sanitizeFilename(strings.Join(words, "-"), "speech")
+	return name + "." + format
+}
+
+// speechDisposition returns the Content-Disposition of generated audio: inline
+// by default, or an attachment named after the text when download is set
+func speechDisposition(r ttsRequest, format string) string {
+	if !r.Download {
+		return fmt.Sprintf(`inline; filename="speech.%s"`, format)
+	}
+	return mime.FormatMediaType("attachment", map[string]string{
+		"filename": speechFilename(r.Text, r.InputFormat, format),
+	})
+}
+
+// ttsModel describes a TTS model family offered by the UI
+type ttsModel struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Family       string `json:"family"`
+	DefaultVoice string `json:"default_voice"`
+}
+
+// ttsModels are the TTS models the UI offers, in display order
+var ttsModels = []ttsModel{
+	{ID: "tts-1", Name: "Kokoro (Neural TTS)", Family: "kokoro", DefaultVoice: "af_nova"},
+	{ID: "tts-1-piper", Name: "Piper (Fast TTS)", Family: "piper", DefaultVoice: "en_US-ryan-medium"},
+}
+
+// defaultTTSModel returns the model used when a request omits one, set with
+// SPEACHES_DEFAULT_MODEL (tts-1 or tts-1-piper); the first offered model otherwise
+func defaultTTSModel() string {
+	if model := os.Getenv("SPEACHES_DEFAULT_MODEL"); ttsVoices[model] != nil {
+		return model
+	}
+	return ttsModels[0].ID
+}
+
+// configuredDefaultVoice returns SPEACHES_DEFAULT_VOICE when model is the
+// default model, so a deployment can prefer e.g. a British voice
+func configuredDefaultVoice(model string) (string, bool) {
+	voice := os.Getenv("SPEACHES_DEFAULT_VOICE")
+	if voice == "" || model != defaultTTSModel() || !ttsVoices[model][voice] {
+		return "", false
+	}
+	return voice, true
+}
+
+// checkTTSDefaults rejects a SPEACHES_DEFAULT_MODEL that is not an offered
+// model, or a SPEACHES_DEFAULT_VOICE that the default model does not have
+func checkTTSDefaults() error {
+	if model := os.Getenv("SPEACHES_DEFAULT_MODEL"); model != "" && ttsVoices[model] == nil {
+		supported := make([]string, len(ttsModels))
+		for i, m := range ttsModels {
+			supported[i] = m.ID
+		}
+		return fmt.Errorf("SPEACHES_DEFAULT_MODEL %q is not a TTS model (use %s)", model, strings.Join(supported, " or "))
+	}
+
+	model := defaultTTSModel()
+	if voice := os.Getenv("SPEACHES_DEFAULT_VOICE"); voice != "" && !ttsVoices[model][voice] {
+		return fmt.Errorf("SPEACHES_DEFAULT_VOICE %q is not a voice of %s; see /api/voices for the voices of each model", voice, model)
+	}
+	return nil
+}
+
+// defaultVoice returns the voice used when a request to model omits one or
+// names an unknown voice: SPEACHES_DEFAULT_VOICE for the default model, or
+// the model's built-in default. With ALLOWED_VOICES set, a default outside
+// the list gives way to the model's first allowed voice.
+func defaultVoice(model string) string {
+	for _, m := range ttsModels {
+		if m.ID != model {
+			continue
+		}
+		voice := m.DefaultVoice
+		if configured, ok := configuredDefaultVoice(model); ok {
+			voice = configured
+		}
+		if allowed := allowedVoices(); allowed != nil && !allowed[voice] {
+			if voices := availableVoices(model); len(voices) > 0 {
+				return voices[0].ID
+			}
+		}
+		return voice
+	}
+	return ""
+}
+
+// kokoroVoices lists the voices accepted by the Kokoro (tts-1) model
+var kokoroVoices = voiceSet("tts-1")
+
+// piperVoices lists the voices accepted by the Piper (tts-1-piper) model
+var piperVoices = voiceSet("tts-1-piper")
+
+// ttsVoices maps each TTS model to the voices it accepts
+var ttsVoices = map[string]map[string]bool{
+	"tts-1":       kokoroVoices,
+	"tts-1-piper": piperVoices,
+}
+
+// ttsRequest is the JSON body accepted by the TTS endpoints
+type ttsRequest struct {
+	Text         string   `json:"text" binding:"required"`
+	Voice        string   `json:"voice"`
+	Model        string   `json:"model"`
+	Format       string   `json:"format"`       // mp3, opus, ogg, webm, aac, wav, flac, pcm
+	Speed        *float64 `json:"speed"`        // 0.25–4.0; omitted uses the upstream default
+	SampleRate   int      `json:"sample_rate"`  // 8000–48000 Hz
+	Share        bool     `json:"share"`        // keep the audio for a shareable link (SHARE_AUDIO=true)
+	Manifest     bool     `json:"manifest"`     // /api/tts/long only: return per-sentence timing as JSON (wav, pcm)
+	AutoDownload *bool    `json:"autodownload"` // overrides AUTO_DOWNLOAD; false fails at once if the model is missing
+	InputFormat  string   `json:"input_format"` // text (default) or ssml
+	Download     bool     `json:"download"`     // send as an attachment named after the text
+}
+
+// ttsOptions are the validated synthesis settings for a request
+type ttsOptions struct {
+	Model       string
+	Voice       string
+	ActualModel string
+	Format      string
+	Speed       *float64
+	SampleRate  int
+	InputFormat string
+}
+
+const (
+	// minTTSSpeed and maxTTSSpeed bound the speed multiplier accepted by speaches.ai
+	minTTSSpeed = 0.25
+	maxTTSSpeed = 4.0
+)
+
+// validateSpeed rejects a speed outside minTTSSpeed–maxTTSSpeed; nil means unset
+func validateSpeed(speed *float64) error {
+	if speed != nil && (*speed < minTTSSpeed || *speed > maxTTSSpeed) {
+		return fmt.Errorf("speed must be between %g and %g", minTTSSpeed, maxTTSSpeed)
+	}
+	return nil
+}
+
+// validateTTSFormat rejects a format speaches.ai can't produce instead of
+// silently switching to MP3; an empty format uses the default
+func validateTTSFormat(format string) error {
+	if _, ok := ttsFormats[format]; format != "" && !ok {
+		return fmt.Errorf("unsupported format: %s (use mp3, opus, ogg, webm, aac, wav, flac or pcm)", format)
+	}
+	return nil
+}
+
+// validateTTSVoice rejects a voice the model does not offer; an empty voice
+// uses the model's default
+func validateTTSVoice(model, voice string) error {
+	if voice == "" || ttsVoices[model] == nil || ttsVoices[model][voice] {
+		return nil
+	}
+	return fmt.Errorf("unknown voice for %s: %s", model, voice)
+}
+
+// rejectUnknownModels reports whether UNKNOWN_MODEL=error, which makes an unknown
+// TTS model a 400 instead of falling back to the default model ("default")
+func rejectUnknownModels() bool {
+	return os.Getenv("UNKNOWN_MODEL") == "error"
+}
+
+// validateTTSModel rejects a model that is not offered when rejectUnknownModels
+// is set; an empty model always uses the default
+func validateTTSModel(model string) error {
+	if model == "" || !rejectUnknownModels() {
+		return nil
+	}
+	return checkTTSModel(model)
+}
+
+// checkTTSModel rejects a model that is not one of ttsModels, naming the supported ones
+func checkTTSModel(model string) error {
+	if ttsVoices[model] != nil {
+		return nil
+	}
+
+	supported := make([]string, len(ttsModels))
+	for i, m := range ttsModels {
+		supported[i] = m.ID
+	}
+	return fmt.Errorf("unknown model: %s (supported: %s)", model, strings.Join(supported, ", "))
+}
+
+// options applies the defaults and limits to a request and resolves the upstream model
+func (r ttsRequest) options() ttsOptions {
+	// Validate and set default format (supported formats: mp3, opus, ogg, webm, aac, wav, flac, pcm)
+	format := r.Format
+	if _, ok := ttsFormats[format]; !ok {
+		format = "mp3" // Default to MP3
+	}
+
+	// Validate and set default sample rate (8000–48000 Hz)
+	sampleRate := r.SampleRate
+	if sampleRate == 0 {
+		sampleRate = 24000 // Default to 24 kHz (good balance of quality and file size)
+	}
+	if sampleRate < 8000 {
+		sampleRate = 8000 // Minimum sample rate
+	}
+	if sampleRate > 48000 {
+		sampleRate = 48000 // Maximum sample rate
+	}
+
+	// Validate the voice and resolve the upstream model ID
+	model, voice, actualModel := resolveTTSModel(r.Model, r.Voice)
+
+	return ttsOptions{
+		Model:       model,
+		Voice:       voice,
+		ActualModel: actualModel,
+		Format:      format,
+		Speed:       r.Speed,
+		SampleRate:  sampleRate,
+		InputFormat: r.InputFormat,
+	}
+}
+
+// payload builds the OpenAI-compatible speech request body for a piece of input text
+func (o ttsOptions) payload(input string) ([]byte, error) {
+	payload := map[string]interface{}{
+		"model":           o.ActualModel,
+		"input":           input,
+		"voice":           o.Voice,
+		"response_format": o.Format,
+		"sample_rate":     o.SampleRate,
+	}
+	// Leave speed out when unset so the upstream default applies
+	if o.Speed != nil {
+		payload["speed"] = *o.Speed
+	}
+	// Tell backends that support SSML how to read the input; others ignore the field
+	if o.InputFormat == "ssml" {
+		payload["input_format"] = "ssml"
+	}
+	return json.Marshal(payload)
+}
+
+// errSpeechAfterDownload is returned when a model was downloaded but the retried synthesis failed to connect
+var errSpeechAfterDownload = errors.New("failed to generate speech after downloading model")
+
+// resolveTTSModel validates the voice for a logical model (tts-1 or tts-1-piper)
+// and returns the normalized model, voice, and the model ID to send upstream
+func resolveTTSModel(model, voice string) (string, string, string) {
+	// Set default model if not provided
+	if model == "" {
+		model = defaultTTSModel()
+	}
+
+	switch model {
+	case "tts-1":
+		if !kokoroVoices[voice] {
+			voice = defaultVoice(model)
+		}
+		return model, voice, "tts-1"
+	case "tts-1-piper":
+		if !piperVoices[voice] {
+			voice = defaultVoice(model)
+		}
+		// For Piper, the model is the full path: speaches-ai/piper-{voice}
+		return model, voice, "speaches-ai/piper-" + voice
+	default:
+		// Unknown model, use the default model and its default voice
+		return resolveTTSModel(defaultTTSModel(), "")
+	}
+}
+
+// isPassthroughTTSModel reports whether model is a concrete model ID to send
+// upstream as-is, rather than one of the tts-1/tts-1-piper aliases: one with a
+// known TTS prefix, or an installed model whose registry type isn't STT
+func isPassthroughTTSModel(ctx context.Context, model string) bool {
+	if model == "" || ttsVoices[model] != nil {
+		return false
+	}
+	if hasTTSModelPrefix(model) {
+		return true
+	}
+
+	baseURL := speachesBaseURL()
+	installed, err := modelRegistry.installedModels(ctx, baseURL, false)
+	return err == nil && installed[model] && modelRegistry.modelTypes(ctx, baseURL).of(model) != "stt"
+}
+
+// isModelNotInstalled reports whether an upstream error body says the requested model is missing
+func isModelNotInstalled(body []byte) bool {
+	return bytes.Contains(body, []byte("is not installed locally")) || (bytes.Contains(body, []byte("Model")) && bytes.Contains(body, []byte("not found")))
+}
+
+// isSpeechError reports whether an upstream speech response is an error. The
+// Content-Type wins over the status: a JSON body is an error envelope even on
+// a 200, and an audio body is a success even on an unusual status.
+func isSpeechError(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return true
+	case strings.HasPrefix(mediaType, "audio/"):
+		return false
+	}
+	return resp.StatusCode != http.StatusOK
+}
+
+// speechErrorStatus picks the status to report for a failed speech response,
+// using 502 when the backend claimed success
+func speechErrorStatus(resp *http.Response) int {
+	if resp.StatusCode < http.StatusBadRequest {
+		return http.StatusBadGateway
+	}
+	return resp.StatusCode
+}
+
+// upstreamErrorMessage extracts the message from a speaches.ai error body,
+// which is either FastAPI's {"detail": ...} or an OpenAI-style {"error": ...}.
+// Bodies that are not a recognized envelope are returned as-is.
+func upstreamErrorMessage(body []byte) string {
+	if message, ok := envelopeMessage(body); ok {
+		return message
+	}
+	return string(body)
+}
+
+// envelopeMessage returns the message of a FastAPI or OpenAI-style error
+// envelope, and false for any other body
+func envelopeMessage(body []byte) (string, bool) {
+	var envelope struct {
+		Detail any `json:"detail"`
+		Error  any `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err == nil {
+		for _, field := range []any{envelope.Detail, envelope.Error} {
+			switch v := field.(type) {
+			case string:
+				return v, true
+			case map[string]any:
+				if message, ok := v["message"].(string); ok {
+					return message, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// postSpeech sends a speech request to speaches.ai, waiting out a model that is
+// still loading. If a Piper voice is not installed yet it is downloaded and the
+// request retried once (unless autodownload is off for the request), and the
+// downloaded model ID is returned alongside the response. On failure the
+// returned response carries the original upstream error body.
+func postSpeech(ctx context.Context, speachesBaseURL, model, voice string, jsonPayload []byte) (*http.Response, string, error) {
+	speachesURL := speachesBaseURL + "/v1/audio/speech"
+
+	send := func() (*http.Response, error) {
+		return retryWhileLoading(ctx, func() (*http.Response, error) {
+			return retryTransient(ctx, func() (*http.Response, error) {
+				return postJSON(ctx, speachesURL, jsonPayload)
+			})
+		})
+	}
+
+	resp, err := send()
+	if err != nil {
+		return nil, "", err
+	}
+	if !isSpeechError(resp) {
+		return resp, "", nil
+	}
+
+	// Keep the error body readable for the caller
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Only Piper voices are downloaded on demand, and only when the request allows it
+	if model != "tts-1-piper" || !isModelNotInstalled(body) || !autoDownloadAllowed(ctx) {
+		return resp, "", nil
+	}
+
+	// Auto-download the Piper voice model
+	// URL-encode the model ID for the download endpoint
+	modelID := "speaches-ai/piper-" + voice
+	downloadURL := speachesBaseURL + "/v1/models/" + url.PathEscape(modelID)
+	downloadResp, err := postJSON(ctx, downloadURL, nil)
+	if err != nil {
+		return resp, "", nil
+	}
+	downloadResp.Body.Close()
+
+	// Retry the TTS request after downloading
+	retryResp, err := send()
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %w", errSpeechAfterDownload, err)
+	}
+	if isSpeechError(retryResp) {
+		// Report the original error rather than the retry's
+		retryResp.Body.Close()
+		return resp, "", nil
+	}
+	return retryResp, modelID, nil
+}
+
+// postJSON sends a POST with a JSON body (which may be nil) bound to ctx
+func postJSON(ctx context.Context, url string, jsonBody []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return speachesClient.Do(req)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestIsSpeechError(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		wantError   bool
+		wantStatus  int
+	}{
+		{"audio on 200", http.StatusOK, "audio/mpeg", false, 0},
+		{"json envelope on 200", http.StatusOK, "application/json", true, http.StatusBadGateway},
+		{"json with charset on 200", http.StatusOK, "application/json; charset=utf-8", true, http.StatusBadGateway},
+		{"problem json on 200", http.StatusOK, "application/problem+json", true, http.StatusBadGateway},
+		{"audio on 203", http.StatusNonAuthoritativeInfo, "audio/wav", false, 0},
+		{"audio on 500", http.StatusInternalServerError, "audio/mpeg", false, 0},
+		{"json on 422", http.StatusUnprocessableEntity, "application/json", true, http.StatusUnprocessableEntity},
+		{"text on 500", http.StatusInternalServerError, "text/plain", true, http.StatusInternalServerError},
+		{"no content type on 200", http.StatusOK, "", false, 0},
+		{"no content type on 503", http.StatusServiceUnavailable, "", true, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			if tt.contentType != "" {
+				resp.Header.Set("Content-Type", tt.contentType)
+			}
+
+			if got := isSpeechError(resp); got != tt.wantError {
+				t.Fatalf("isSpeechError() = %v, want %v", got, tt.wantError)
+			}
+			if tt.wantError {
+				if got := speechErrorStatus(resp); got != tt.wantStatus {
+					t.Errorf("speechErrorStatus() = %d, want %d", got, tt.wantStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestUpstreamErrorMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"fastapi detail", `{"detail":"Model not found"}`, "Model not found"},
+		{"openai error", `{"error":{"message":"bad voice","type":"invalid_request_error"}}`, "bad voice"},
+		{"plain error string", `{"error":"boom"}`, "boom"},
+		{"other json", `{"foo":1}`, `{"foo":1}`},
+		{"plain text", "Internal Server Error", "Internal Server Error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := upstreamErrorMessage([]byte(tt.body)); got != tt.want {
+				t.Errorf("upstreamErrorMessage(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeTTSText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain", "Hello world.", "Hello world."},
+		{"byte order mark", "\ufeffHello world.", "Hello world."},
+		{"crlf", "One.\r\nTwo.\r\nThree.", "One.\nTwo.\nThree."},
+		{"bare cr", "One.\rTwo.", "One.\nTwo."},
+		{"bom and crlf", "\ufeffOne.\r\n\r\nTwo.\r\n", "One.\n\nTwo."},
+		{"surrounding whitespace", " \t Hello. \n", "Hello."},
+		{"inner bom kept", "a\ufeffb", "a\ufeffb"},
+		{"whitespace only", "\ufeff \r\n\t", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeTTSText(tt.text); got != tt.want {
+				t.Errorf("normalizeTTSText(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Build information, set at build time with
+// -ldflags "-X main.version=... -X main.commit=... -X main.date=..."
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
+)
+
+// handleVersion reports which build of speaches-ui is running, the Go
+// release it was built with and the checksum of its embedded assets
+func handleVersion(c *gin.Context) {
+	assets, _ := embeddedChecksum()
+	c.JSON(http.StatusOK, gin.H{
+		"version":         version,
+		"commit":          commit,
+		"date":            date,
+		"go_version":      runtime.Version(),
+		"assets_checksum": assets,
+	})
+}
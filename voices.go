@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ttsVoice is one built-in voice of a TTS model
+type ttsVoice struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Locale string `json:"locale"` // en-US or en-GB
+	Gender string `json:"gender"` // female, male, or mixed for multi-speaker voices
+
+	// Models lists every model offering a voice with this id, set in API
+	// responses so a voice shared by several families can be told apart
+	Models []string `json:"models,omitempty"`
+}
+
+// voiceCatalog is the single source of truth for the built-in voices of each
+// TTS model, in display order. The validation sets are derived from it.
+var voiceCatalog = map[string][]ttsVoice{
+	"tts-1": {
+		{ID: "af_nova", Name: "Nova (Neutral)", Locale: "en-US", Gender: "female"},
+		{ID: "af_sarah", Name: "Sarah (Clear)", Locale: "en-US", Gender: "female"},
+		{ID: "af_bella", Name: "Bella (Warm)", Locale: "en-US", Gender: "female"},
+		{ID: "af_heart", Name: "Heart (Expressive)", Locale: "en-US", Gender: "female"},
+		{ID: "af_aoede", Name: "Aoede (Bright)", Locale: "en-US", Gender: "female"},
+		{ID: "af_jessica", Name: "Jessica (Smooth)", Locale: "en-US", Gender: "female"},
+		{ID: "af_kore", Name: "Kore (Dynamic)", Locale: "en-US", Gender: "female"},
+		{ID: "af_nicole", Name: "Nicole (Natural)", Locale: "en-US", Gender: "female"},
+		{ID: "af_river", Name: "River (Calm)", Locale: "en-US", Gender: "female"},
+		{ID: "af_sky", Name: "Sky (Gentle)", Locale: "en-US", Gender: "female"},
+		{ID: "af_alloy", Name: "Alloy (Balanced)", Locale: "en-US", Gender: "female"},
+		{ID: "am_adam", Name: "Adam (Friendly)", Locale: "en-US", Gender: "male"},
+		{ID: "am_echo", Name: "Echo (Deep)", Locale: "en-US", Gender: "male"},
+		{ID: "am_liam", Name: "Liam (Professional)", Locale: "en-US", Gender: "male"},
+		{ID: "am_onyx", Name: "Onyx (Commanding)", Locale: "en-US", Gender: "male"},
+		{ID: "am_michael", Name: "Michael (Energetic)", Locale: "en-US", Gender: "male"},
+		{ID: "am_eric", Name: "Eric (Smooth)", Locale: "en-US", Gender: "male"},
+		{ID: "am_fenrir", Name: "Fenrir (Intense)", Locale: "en-US", Gender: "male"},
+		{ID: "am_puck", Name: "Puck (Playful)", Locale: "en-US", Gender: "male"},
+		{ID: "am_santa", Name: "Santa (Jolly)", Locale: "en-US", Gender: "male"},
+		{ID: "bf_alice", Name: "Alice (Posh)", Locale: "en-GB", Gender: "female"},
+		{ID: "bf_emma", Name: "Emma (Refined)", Locale: "en-GB", Gender: "female"},
+		{ID: "bf_isabella", Name: "Isabella (Elegant)", Locale: "en-GB", Gender: "female"},
+		{ID: "bf_lily", Name: "Lily (Sweet)", Locale: "en-GB", Gender: "female"},
+		{ID: "bm_fable", Name: "Fable (Theatrical)", Locale: "en-GB", Gender: "male"},
+		{ID: "bm_george", Name: "George (Distinguished)", Locale: "en-GB", Gender: "male"},
+		{ID: "bm_daniel", Name: "Daniel (Smooth)", Locale: "en-GB", Gender: "male"},
+		{ID: "bm_lewis", Name: "Lewis (Rich)", Locale: "en-GB", Gender: "male"},
+	},
+	"tts-1-piper": {
+		{ID: "en_US-ryan-high", Name: "Ryan High", Locale: "en-US", Gender: "male"},
+		{ID: "en_US-ryan-medium", Name: "Ryan Medium", Locale: "en-US", Gender: "male"},
+		{ID: "en_US-ryan-low", Name: "Ryan Low", Locale: "en-US", Gender: "male"},
+		{ID: "en_US-hfc_female-medium", Name: "HFC Female", Locale: "en-US", Gender: "female"},
+		{ID: "en_US-amy-medium", Name: "Amy Medium", Locale: "en-US", Gender: "female"},
+		{ID: "en_US-amy-low", Name: "Amy Low", Locale: "en-US", Gender: "female"},
+		{ID: "en_US-kathleen-low", Name: "Kathleen", Locale: "en-US", Gender: "female"},
+		{ID: "en_US-kristin-medium", Name: "Kristin", Locale: "en-US", Gender: "female"},
+		{ID: "en_US-ljspeech-high", Name: "LJ Speech High", Locale: "en-US", Gender: "female"},
+		{ID: "en_US-ljspeech-medium", Name: "LJ Speech Medium", Locale: "en-US", Gender: "female"},
+		{ID: "en_US-hfc_male-medium", Name: "HFC Male", Locale: "en-US", Gender: "male"},
+		{ID: "en_US-lessac-high", Name: "Lessac High", Locale: "en-US", Gender: "male"},
+		{ID: "en_US-lessac-medium", Name: "Lessac Medium", Locale: "en-US", Gender: "male"},
+		{ID: "en_US-lessac-low", Name: "Lessac Low", Locale: "en-US", Gender: "male"},
+		{ID: "en_US-danny-low", Name: "Danny", Locale: "en-US", Gender: "male"},
+		{ID: "en_US-joe-medium", Name: "Joe", Locale: "en-US", Gender: "male"},
+		{ID: "en_US-john-medium", Name: "John", Locale: "en-US", Gender: "male"},
+		{ID: "en_US-bryce-medium", Name: "Bryce", Locale: "en-US", Gender: "male"},
+		{ID: "en_US-kusal-medium", Name: "Kusal", Locale: "en-US", Gender: "male"},
+		{ID: "en_US-norman-medium", Name: "Norman", Locale: "en-US", Gender: "male"},
+		{ID: "en_US-libritts-high", Name: "LibriTTS High", Locale: "en-US", Gender: "mixed"},
+		{ID: "en_US-libritts_r-medium", Name: "LibriTTS-R Medium", Locale: "en-US", Gender: "mixed"},
+		{ID: "en_US-arctic-medium", Name: "Arctic", Locale: "en-US", Gender: "mixed"},
+		{ID: "en_US-l2arctic-medium", Name: "L2 Arctic", Locale: "en-US", Gender: "mixed"},
+		{ID: "en_GB-alan-medium", Name: "Alan Medium", Locale: "en-GB", Gender: "male"},
+		{ID: "en_GB-alan-low", Name: "Alan Low", Locale: "en-GB", Gender: "male"},
+		{ID: "en_GB-northern_english_male-medium", Name: "Northern Male", Locale: "en-GB", Gender: "male"},
+		{ID: "en_GB-southern_english_female-low", Name: "Southern Female", Locale: "en-GB", Gender: "female"},
+		{ID: "en_GB-alba-medium", Name: "Alba", Locale: "en-GB", Gender: "female"},
+		{ID: "en_GB-cori-high", Name: "Cori High", Locale: "en-GB", Gender: "female"},
+		{ID: "en_GB-cori-medium", Name: "Cori Medium", Locale: "en-GB", Gender: "female"},
+		{ID: "en_GB-jenny_dioco-medium", Name: "Jenny Dioco", Locale: "en-GB", Gender: "female"},
+		{ID: "en_GB-aru-medium", Name: "Aru", Locale: "en-GB", Gender: "mixed"},
+		{ID: "en_GB-semaine-medium", Name: "Semaine", Locale: "en-GB", Gender: "mixed"},
+		{ID: "en_GB-vctk-medium", Name: "VCTK", Locale: "en-GB", Gender: "mixed"},
+	},
+}
+
+// voiceSet returns the ids of a model's catalog voices for validation
+func voiceSet(model string) map[string]bool {
+	set := make(map[string]bool, len(voiceCatalog[model]))
+	for _, voice := range voiceCatalog[model] {
+		set[voice.ID] = true
+	}
+	return set
+}
+
+// voiceModels returns the models whose catalog has a voice with id, in ttsModels order
+func voiceModels(id string) []string {
+	var models []string
+	for _, m := range ttsModels {
+		for _, voice := range voiceCatalog[m.ID] {
+			if voice.ID == id {
+				models = append(models, m.ID)
+				break
+			}
+		}
+	}
+	return models
+}
+
+// withVoiceModels returns copies of voices annotated with the models each belongs to
+func withVoiceModels(voices []ttsVoice) []ttsVoice {
+	annotated := make([]ttsVoice, len(voices))
+	for i, voice := range voices {
+		voice.Models = voiceModels(voice.ID)
+		annotated[i] = voice
+	}
+	return annotated
+}
+
+// voiceGroup is a set of voices sharing a locale and gender, e.g. American Female
+type voiceGroup struct {
+	Label  string     `json:"label"`
+	Locale string     `json:"locale"`
+	Gender string     `json:"gender"`
+	Voices []ttsVoice `json:"voices"`
+}
+
+// voiceGroupLabel names a locale/gender group the way the TTS page shows it
+func voiceGroupLabel(locale, gender string) string {
+	label := locale
+	switch locale {
+	case "en-US":
+		label = "American"
+	case "en-GB":
+		label = "British"
+	}
+
+	switch gender {
+	case "female":
+		return label + " Female"
+	case "male":
+		return label + " Male"
+	default:
+		return label + " Multi-speaker"
+	}
+}
+
+// groupVoices groups voices by locale and gender, keeping catalog order
+func groupVoices(voices []ttsVoice) []voiceGroup {
+	groups := []voiceGroup{}
+	index := map[string]int{}
+	for _, voice := range voices {
+		key := voice.Locale + "/" + voice.Gender
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, voiceGroup{
+				Label:  voiceGroupLabel(voice.Locale, voice.Gender),
+				Locale: voice.Locale,
+				Gender: voice.Gender,
+			})
+		}
+		groups[i].Voices = append(groups[i].Voices, voice)
+	}
+	return groups
+}
+
+// handleGetVoiceCatalog returns the built-in voice sets of each model family,
+// grouped by locale and gender. This is the static catalog, independent of
+// which voices are installed on the backend.
+func handleGetVoiceCatalog(c *gin.Context) {
+	families := make([]gin.H, 0, len(ttsModels))
+	for _, m := range ttsModels {
+		families = append(families, gin.H{
+			"model":  m.ID,
+			"family": m.Family,
+			"groups": groupVoices(withVoiceModels(availableVoices(m.ID))),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"families": families})
+}
+
+// handleGetVoices returns the known voices of each TTS model, keyed by model
+// and grouped by locale and gender, so the TTS page's voice dropdowns match
+// what requests are validated against. Each voice names the models it belongs
+// to, so one that appears under several is never mistaken for the same voice.
+func handleGetVoices(c *gin.Context) {
+	models := make(gin.H, len(ttsModels))
+	total := 0
+	for _, m := range ttsModels {
+		voices := availableVoices(m.ID)
+		total += len(voices)
+		models[m.ID] = groupVoices(withVoiceModels(voices))
+	}
+
+	response := gin.H{"models": models}
+	if warning := voicesWarning(c.Request.Context(), total); warning != "" {
+		response["warning"] = warning
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// voicesWarning explains an empty voice list, or a backend that has models but
+// no TTS ones, so new users are pointed at the setup flow instead of an empty
+// dropdown. It returns "" when there is nothing to warn about or the backend
+// can't be reached to tell.
+func voicesWarning(ctx context.Context, available int) string {
+	if available == 0 {
+		return "no TTS voices are available; check ALLOWED_VOICES"
+	}
+
+	installed, err := modelRegistry.installedModels(ctx, speachesBaseURL(), false)
+	if err != nil {
+		return ""
+	}
+	for id := range installed {
+		if !isSTTModel(id) {
+			return ""
+		}
+	}
+	return "no TTS voices installed; visit Add TTS Models"
+}
+
+// defaultSampleLanguage is used for voices whose language has no sample text
+const defaultSampleLanguage = "en"
+
+// voiceSampleTexts are voice preview sentences, keyed by ISO 639-1 language code
+var voiceSampleTexts = map[string]string{
+	"en": "Hello! This is how I sound when I read your text aloud.",
+	"es": "¡Hola! Así es como sueno cuando leo tu texto en voz alta.",
+	"fr": "Bonjour ! Voici ma voix quand je lis votre texte à voix haute.",
+	"de": "Hallo! So klinge ich, wenn ich deinen Text laut vorlese.",
+	"it": "Ciao! Ecco come suono quando leggo il tuo testo ad alta voce.",
+	"pt": "Olá! É assim que eu soo quando leio o seu texto em voz alta.",
+	"hi": "नमस्ते! आपका पाठ ज़ोर से पढ़ते समय मेरी आवाज़ ऐसी सुनाई देती है।",
+	"ja": "こんにちは。これが、あなたの文章を読み上げるときの私の声です。",
+	"zh": "你好！这就是我朗读你的文字时的声音。",
+}
+
+// voiceSampleText returns the language of a locale such as en-GB and the preview sentence for it
+func voiceSampleText(locale string) (string, string) {
+	language, _, _ := strings.Cut(locale, "-")
+	language = strings.ToLower(language)
+	if text, ok := voiceSampleTexts[language]; ok {
+		return language, text
+	}
+	return defaultSampleLanguage, voiceSampleTexts[defaultSampleLanguage]
+}
+
+// handleGetVoiceSamples returns a preview sentence in each voice's own language
+func handleGetVoiceSamples(c *gin.Context) {
+	samples := []gin.H{}
+	for _, m := range ttsModels {
+		for _, voice := range availableVoices(m.ID) {
+			language, text := voiceSampleText(voice.Locale)
+			samples = append(samples, gin.H{
+				"model":    m.ID,
+				"id":       voice.ID,
+				"name":     voice.Name,
+				"locale":   voice.Locale,
+				"language": language,
+				"sample":   text,
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"voices": samples})
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVoiceModels(t *testing.T) {
+	// A custom catalog may offer the same voice id under two models
+	piper := voiceCatalog["tts-1-piper"]
+	voiceCatalog["tts-1-piper"] = append(append([]ttsVoice{}, piper...), ttsVoice{ID: "af_nova", Name: "Nova", Locale: "en-US", Gender: "female"})
+	t.Cleanup(func() { voiceCatalog["tts-1-piper"] = piper })
+
+	tests := []struct {
+		voice string
+		want  []string
+	}{
+		{"af_bella", []string{"tts-1"}},
+		{"en_US-ryan-high", []string{"tts-1-piper"}},
+		{"af_nova", []string{"tts-1", "tts-1-piper"}},
+		{"nobody", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.voice, func(t *testing.T) {
+			if got := voiceModels(tt.voice); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("voiceModels(%q) = %v, want %v", tt.voice, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithVoiceModelsCopies(t *testing.T) {
+	voices := voiceCatalog["tts-1"][:1]
+	annotated := withVoiceModels(voices)
+	if !reflect.DeepEqual(annotated[0].Models, []string{"tts-1"}) {
+		t.Errorf("Models = %v, want [tts-1]", annotated[0].Models)
+	}
+	if voices[0].Models != nil {
+		t.Error("withVoiceModels modified the catalog")
+	}
+}